@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// command describes a subcommand for the usage text and shell completion
+type command struct {
+	name        string
+	args        string               // Argument synopsis shown in the usage text
+	summary     string               // One-line description
+	subcommands []string             // Words completed after the command name
+	flags       func() *flag.FlagSet // Flags the command parses after its name, if any
+}
+
+// commands lists every subcommand in the order the usage text shows them
+var commands = []command{
+	{name: "run", args: "[--detach] <query>", summary: "Run a task with the given query (--detach runs it in the background)",
+		flags: func() *flag.FlagSet { fs, _ := newRunFlags(); return fs }},
+	{name: "explain", args: "<query>", summary: "Ask for an explanation and suggested commands; nothing is executed"},
+	{name: "cancel", args: "<id>", summary: "Cancel a running task by ID (as shown by list)"},
+	{name: "list", summary: "List the tasks started by run, with their status and pid"},
+	{name: "config", summary: "Show the effective settings and where each comes from (flag, env, file or default)"},
+	{name: "sessions", args: "list|delete <name>", summary: "List or delete saved --session conversations", subcommands: []string{"list", "delete"}},
+	{name: "review", args: "[n]", summary: "Review and re-rate the last n logged tool calls (default 10)"},
+	{name: "log", args: "[flags]", summary: "Show recent logged tool calls (-n, --follow, --tool, --status, --since, --json) or a detached task's output (--task)",
+		flags: func() *flag.FlagSet { fs, _ := newLogFlags(); return fs }},
+	{name: "prune", args: "[flags]", summary: "Keep only log entries matching -min-rating, -since, -before and -success (-dry-run to preview)",
+		flags: func() *flag.FlagSet { fs, _ := newPruneFlags(); return fs }},
+	{name: "log-diff", args: "<old.log> <new.log>", summary: "Summarize the entries in new.log that are not in old.log, by tool and rating"},
+	{name: "replay", args: "<n>", summary: "Re-run logged tool call number n (as numbered by log) after confirmation"},
+	{name: "export-script", args: "<pattern>", summary: "Print a bash script of the successful commands for queries matching pattern"},
+	{name: "batch", args: "<file>", summary: "Run every query in a file (one per line or JSONL), tools off unless --tools is given"},
+	{name: "generate", args: "<prompt>", summary: "Send a raw prompt to the /api/generate endpoint (no system prompt or tools)"},
+	{name: "doctor", summary: "Check the endpoint, the model and whether the model supports tool calling"},
+	{name: "completion", args: "bash|zsh|fish", summary: "Print a shell completion script", subcommands: completionShells},
+}
+
+// printCommands prints the command list of the usage text
+func printCommands() {
+	for _, c := range commands {
+		fmt.Printf("  %-32s - %s\n", c.name+" "+c.args, c.summary)
+	}
+}
+
+// newRunFlags defines the flags of the run command
+func newRunFlags() (*flag.FlagSet, *bool) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	return fs, fs.Bool("detach", false, "Run the task in the background and print its ID; see list, cancel and log --task")
+}
+
+// logArgs are the flags of the log command
+type logArgs struct {
+	limit  *int
+	follow *bool
+	tool   *string
+	status *string
+	asJSON *bool
+	since  *string
+	task   *string
+}
+
+func newLogFlags() (*flag.FlagSet, *logArgs) {
+	fs := flag.NewFlagSet("log", flag.ExitOnError)
+	return fs, &logArgs{
+		limit:  fs.Int("n", 20, "Number of most recent entries to show (0 for all)"),
+		follow: fs.Bool("follow", false, "Keep printing new entries as they are logged"),
+		tool:   fs.String("tool", "", "Only show entries for this tool"),
+		status: fs.String("status", "", "Only show entries with this status (success, error, denied, cancelled)"),
+		asJSON: fs.Bool("json", false, "Print entries as JSON lines"),
+		since:  fs.String("since", "", "Only show entries logged since a duration ago (36h, 7d), a date (2006-01-02) or an RFC 3339 time"),
+		task:   fs.String("task", "", "Show the output of a task started with run --detach (with --follow, until it finishes)"),
+	}
+}
+
+// pruneArgs are the flags of the prune command
+type pruneArgs struct {
+	minRating   *int
+	since       *string
+	before      *string
+	successOnly *bool
+	dryRun      *bool
+}
+
+func newPruneFlags() (*flag.FlagSet, *pruneArgs) {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	return fs, &pruneArgs{
+		minRating:   fs.Int("min-rating", 0, "Keep only entries rated at least this (1-5)"),
+		since:       fs.String("since", "", "Keep only entries logged since a duration ago (36h, 7d), a date or an RFC 3339 time"),
+		before:      fs.String("before", "", "Keep only entries logged before a duration ago, a date or an RFC 3339 time"),
+		successOnly: fs.Bool("success", false, "Keep only successful tool calls"),
+		dryRun:      fs.Bool("dry-run", false, "Show how many entries would be removed without changing the log"),
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// completionShells are the shells writeCompletion supports
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// writeCompletion writes a completion script for shell, built from the
+// global flags and the commands table
+func writeCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w)
+	case "zsh":
+		// zsh runs the bash function through its bash compatibility layer
+		fmt.Fprintln(w, "#compdef tinypenguin-cli")
+		fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit")
+		writeBashCompletion(w)
+	case "fish":
+		writeFishCompletion(w)
+	default:
+		return fmt.Errorf("completion requires a shell: %s", strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+// flagNames returns the flags of fs as typed on the command line, and those
+// of them that take a value
+func flagNames(fs *flag.FlagSet) (all, withValue []string) {
+	fs.VisitAll(func(f *flag.Flag) {
+		name := flagSpelling(f.Name)
+		all = append(all, name)
+		if !isBoolFlag(f) {
+			withValue = append(withValue, name)
+		}
+	})
+	return all, withValue
+}
+
+// flagSpelling returns "-n" for one-letter flags and "--name" otherwise
+func flagSpelling(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func writeBashCompletion(w io.Writer) {
+	globals, withValue := flagNames(flag.CommandLine)
+	var names []string
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+
+	fmt.Fprintf(w, `_tinypenguin_cli() {
+	local cur="${COMP_WORDS[COMP_CWORD]}" cmd="" i
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case "${COMP_WORDS[i]}" in
+		%s) ((i++)) ;;
+		-*) ;;
+		*) cmd="${COMP_WORDS[i]}"; break ;;
+		esac
+	done
+
+	local words=""
+	case "$cmd" in
+	"")
+		if [[ "$cur" == -* ]]; then words="%s"; else words="%s"; fi ;;
+`, strings.Join(withValue, "|"), strings.Join(globals, " "), strings.Join(names, " "))
+	for _, c := range commands {
+		var words []string
+		if c.flags != nil {
+			words, _ = flagNames(c.flags())
+		}
+		words = append(words, c.subcommands...)
+		if len(words) > 0 {
+			fmt.Fprintf(w, "\t%s) words=%q ;;\n", c.name, strings.Join(words, " "))
+		}
+	}
+	fmt.Fprint(w, `	esac
+	COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -o default -F _tinypenguin_cli tinypenguin-cli
+`)
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "complete -c tinypenguin-cli -f")
+	flag.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(w, "complete -c tinypenguin-cli -n __fish_use_subcommand %s\n", fishFlag(f))
+	})
+	for _, c := range commands {
+		fmt.Fprintf(w, "complete -c tinypenguin-cli -n __fish_use_subcommand -a %s -d %s\n", c.name, fishQuote(c.summary))
+		condition := fishQuote("__fish_seen_subcommand_from " + c.name)
+		if len(c.subcommands) > 0 {
+			fmt.Fprintf(w, "complete -c tinypenguin-cli -n %s -a %s\n", condition, fishQuote(strings.Join(c.subcommands, " ")))
+		}
+		if c.flags != nil {
+			c.flags().VisitAll(func(f *flag.Flag) {
+				fmt.Fprintf(w, "complete -c tinypenguin-cli -n %s %s\n", condition, fishFlag(f))
+			})
+		}
+	}
+}
+
+// fishFlag returns the complete options describing a flag
+func fishFlag(f *flag.Flag) string {
+	option := "-l " + f.Name
+	if len(f.Name) == 1 {
+		option = "-s " + f.Name
+	}
+	if !isBoolFlag(f) {
+		option += " -r"
+	}
+	return option + " -d " + fishQuote(f.Usage)
+}
+
+// fishQuote single-quotes s for fish
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"example.com/tinypenguin/pkg/cli"
+)
+
+// envFlags are the flags whose default comes from an environment variable,
+// which may also be set in a .env file
+var envFlags = map[string]string{
+	"url":   "TINYLLAMA_URL",
+	"model": "MODEL",
+
+	"max-log-entries": "TINYPENGUIN_MAX_LOG_ENTRIES",
+	"rating-enabled":  "TINYPENGUIN_RATING_ENABLED",
+}
+
+// ratingEnabled resolves whether tool calls are rated and where that came
+// from: --rating-enabled, then TINYPENGUIN_RATING_ENABLED, then
+// rating.enabled in the config file, enabled by default
+func ratingEnabled() (bool, string) {
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "rating-enabled" {
+			explicit = true
+		}
+	})
+	switch {
+	case explicit:
+		return *rateEnabled, "flag --rating-enabled"
+	case os.Getenv(envFlags["rating-enabled"]) != "":
+		return *rateEnabled, "env " + envFlags["rating-enabled"]
+	case fileConfig.Rating.Enabled != nil:
+		return *fileConfig.Rating.Enabled, "rating.enabled in " + *configFile
+	}
+	return true, "default"
+}
+
+// printEffectiveConfig prints every setting with its resolved value and
+// whether it came from a flag, the environment, the config file or the
+// built-in default
+func printEffectiveConfig() {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+		if long, ok := strings.CutPrefix(f.Usage, "Shorthand for --"); ok {
+			explicit[long] = true
+		}
+	})
+
+	row := func(name, value, source string) {
+		if value == "" {
+			value = `""`
+		}
+		fmt.Printf("%-22s  %-40s  %s\n", name, value, source)
+	}
+	fmt.Printf("%-22s  %-40s  %s\n", "SETTING", "VALUE", "SOURCE")
+	flag.VisitAll(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Usage, "Shorthand for ") {
+			return
+		}
+		source := "default"
+		switch {
+		case explicit[f.Name]:
+			source = "flag"
+		case envFlags[f.Name] != "" && os.Getenv(envFlags[f.Name]) != "":
+			source = "env (" + envFlags[f.Name] + ")"
+		}
+		row("--"+f.Name, f.Value.String(), source)
+	})
+
+	configSource := "file " + *configFile
+	if _, err := os.Stat(*configFile); errors.Is(err, os.ErrNotExist) {
+		configSource = "default (" + *configFile + " does not exist)"
+	}
+	rotation, rotationSource := fileConfig.LogRotation, configSource
+	if rotation == "" {
+		rotation, rotationSource = cli.LogRotationOldest, "default"
+	}
+	row("log_rotation", rotation, rotationSource)
+	rating, ratingSource := ratingEnabled()
+	row("rating.enabled", strconv.FormatBool(rating), ratingSource)
+	var tools []string
+	for _, tool := range fileConfig.Tools {
+		tools = append(tools, tool.Name)
+	}
+	toolsSource := configSource
+	if len(tools) == 0 {
+		toolsSource = "default"
+	}
+	row("tools", strings.Join(tools, ","), toolsSource)
+}
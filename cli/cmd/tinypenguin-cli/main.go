@@ -1,13 +1,21 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"golang.org/x/term"
 	"example.com/tinypenguin/pkg/cli"
+	"example.com/tinypenguin/pkg/common"
 )
 
 // getDefaultModel returns the default model from environment or fallback
@@ -26,12 +34,124 @@ func getDefaultURL() string {
 	return "http://localhost:11434/v1"
 }
 
+// applyMaxLogEntriesEnv sets the log size from TINYPENGUIN_MAX_LOG_ENTRIES
+// unless --max-log-entries was given. It runs after flag.Parse, so a bad
+// value fails the command instead of every invocation including --help.
+func applyMaxLogEntriesEnv() error {
+	value := os.Getenv("TINYPENGUIN_MAX_LOG_ENTRIES")
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "max-log-entries" {
+			explicit = true
+		}
+	})
+	if value == "" || explicit {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("TINYPENGUIN_MAX_LOG_ENTRIES must be a number, got %q", value)
+	}
+	if n < 0 {
+		return fmt.Errorf("TINYPENGUIN_MAX_LOG_ENTRIES must not be negative, got %d", n)
+	}
+	*maxLogSize = n
+	return nil
+}
+
+// applyRatingEnabledEnv sets --rating-enabled from TINYPENGUIN_RATING_ENABLED
+// unless the flag was given. Like applyMaxLogEntriesEnv it runs after
+// flag.Parse, so a bad value does not stop --help.
+func applyRatingEnabledEnv() error {
+	value := os.Getenv("TINYPENGUIN_RATING_ENABLED")
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "rating-enabled" {
+			explicit = true
+		}
+	})
+	if value == "" || explicit {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("TINYPENGUIN_RATING_ENABLED must be true or false, got %q", value)
+	}
+	*rateEnabled = enabled
+	return nil
+}
+
 var (
 	tinyllamaURL *string
 	model        *string
 	taskID       *string
 	toolsEnabled *bool
 	debugMode    *bool
+	noRate       *bool
+	rateContext  *bool
+	fixedRating  *int
+	rateEnabled  *bool
+	maxTools     *int
+	maxLogSize   *int
+	rootDir      *string
+	cmdWrapper   *string
+	shellName    *string
+	stdinFile    *string
+	cacheTTL     *time.Duration
+	saveScript   *string
+	logLevel     *string
+	logFormat    *string
+	verbose      bool
+	quiet        bool
+	planMode     *bool
+	assumeYes    bool
+	concurrency  *int
+	modelFallbk  *string
+	maxOutput    *int
+	contextToks  *int
+	auditLog     *string
+	outputDir    *string
+	summarizeOut *int
+	preflight    *bool
+	apiStyle     *string
+	noColor      *bool
+	stream       *bool
+	envPass      *string
+	cleanEnv     *bool
+	workdir      *string
+	rawOutput    *bool
+	images       *string
+	allowNetwork *bool
+	autoExec     *bool
+	editCalls    *bool
+	safeFlag     *bool
+	unsafeFlag   *bool
+	runAs        *string
+	seed         *int
+	keepAlive    *string
+	stripThink   *bool
+	recordFile   *string
+	toolChoice   *string
+	forceTools   *bool
+	retryTool    *bool
+	promptExamp  *bool
+	omitToolList *bool
+	timestamps   *bool
+	configFile   *string
+	maxResponse  *int64
+	sessionName  *string
+	contextFile  *string
+	httpTrace    *bool
+	confirmWait  *time.Duration
+	taskTimeout  *time.Duration
+	dumpPrompt   *bool
+	parallelTool *int
+	maxParallel  *int
+	replayFile   *string
+	thinkTags    *string
+	repeatCount  int
+
+	fileConfig *cli.Config // Loaded from --config at startup
 )
 
 func init() {
@@ -44,10 +164,347 @@ func init() {
 	taskID = flag.String("task-id", "", "Task ID for cancel/list operations")
 	toolsEnabled = flag.Bool("tools", true, "Enable tool calling (default: true)")
 	debugMode = flag.Bool("debug", false, "Enable debug output to diagnose tool calling issues")
+	noRate = flag.Bool("no-rate", false, "Skip the rating prompt and log tool calls unrated")
+	rateContext = flag.Bool("rating-context", true, "Repeat the command, status and first/last output lines above the rating prompt (--rating-context=false for terse prompts)")
+	fixedRating = flag.Int("rate", 0, "Assign a fixed 1-5 rating to every tool call instead of prompting")
+	rateEnabled = flag.Bool("rating-enabled", true, "Rate tool calls for training data; false never prompts and logs no ratings (also TINYPENGUIN_RATING_ENABLED, or rating.enabled in the config file)")
+	maxTools = flag.Int("max-tools", 10, "Maximum number of tool executions per run (0 for unlimited)")
+	maxLogSize = flag.Int("max-log-entries", cli.DefaultMaxLogEntries, "Entries kept in tool_calls.log before the oldest are rotated out (0 for unlimited; default $TINYPENGUIN_MAX_LOG_ENTRIES if set)")
+	rootDir = flag.String("root", "", "Restrict file tools to paths inside this directory")
+	cacheTTL = flag.Duration("cache-ttl", 30*time.Second, "Reuse the result of deterministic commands such as pwd, whoami or uname run again within this time (0 disables); any other tool call clears the cache")
+	saveScript = flag.String("save-script", "", "Write the commands and edits that succeeded to this file as a bash script that repeats the run")
+	stdinFile = flag.String("stdin-file", "", "Give this file to the standard input of every command the model runs without its own stdin, e.g. a dump for mysql")
+	shellName = flag.String("shell", "", "Shell that runs commands with -c (default bash, or sh when bash is missing)")
+	cmdWrapper = flag.String("command-wrapper", "", "Run every command through this wrapper (e.g. \"firejail --quiet\")")
+	logLevel = flag.String("log-level", "warn", "Operational log level written to stderr: debug, info, warn or error")
+	logFormat = flag.String("log-format", "text", "Operational log format: text or json")
+	flag.BoolVar(&verbose, "verbose", false, "Show step-level progress (model, tools offered, finish reason, tokens)")
+	flag.BoolVar(&verbose, "v", false, "Shorthand for --verbose")
+	flag.BoolVar(&quiet, "quiet", false, "Print only the final answer or command output; errors go to stderr")
+	flag.BoolVar(&quiet, "q", false, "Shorthand for --quiet")
+	maxParallel = flag.Int("max-parallel-commands", 1, "Most shell commands running at once, however many tool calls run in parallel (defaults to --parallel-tools when that is set)")
+	parallelTool = flag.Int("parallel-tools", 0, "Run up to this many read-only tool calls from one response at once (0 runs every call in order)")
+	dumpPrompt = flag.Bool("dump-prompt", false, "Print the assembled system prompt and messages, then exit without calling the model")
+	taskTimeout = flag.Duration("task-timeout", 0, "Stop the whole task, killing running commands, after this long, e.g. 5m (0 for unlimited)")
+	confirmWait = flag.Duration("confirm-timeout", 0, "Answer confirmation prompts with no after this long without input, e.g. 2m (0 waits forever)")
+	planMode = flag.Bool("plan", false, "Show the tool calls the model proposes and ask before executing them")
+	flag.BoolVar(&assumeYes, "yes", false, "Apply file edits and package installs/removals without asking for confirmation (also turns off the --safe default)")
+	flag.BoolVar(&assumeYes, "y", false, "Shorthand for --yes")
+	concurrency = flag.Int("concurrency", 1, "Number of batch queries to run at once")
+	modelFallbk = flag.String("model-fallback", "", "Comma-separated models to try in order if --model is not available")
+	maxOutput = flag.Int("max-output-bytes", 0, "Truncate tool output fed to the model and log to this many bytes (0 for unlimited)")
+	contextToks = flag.Int("context-tokens", 0, "Estimated token budget for the conversation; oldest turns are dropped beyond it (0 for unlimited)")
+	envPass = flag.String("env-passthrough", "", "Comma-separated environment variables commands may see (NAME or PREFIX*); others are dropped")
+	rawOutput = flag.Bool("raw-output", false, "Keep binary output and control characters instead of suppressing them")
+	workdir = flag.String("workdir", "", "Directory commands run in and relative paths resolve against (must be inside --root if set)")
+	cleanEnv = flag.Bool("clean-env", false, "Run commands with a minimal PATH and only the --env-passthrough variables")
+	stream = flag.Bool("stream", false, "Print the generate completion as it is produced")
+	noColor = flag.Bool("no-color", false, "Disable colored output (also disabled when NO_COLOR is set or stdout is not a terminal)")
+	apiStyle = flag.String("api-style", common.APIStyleOpenAI, "Endpoint API: openai (/v1/chat/completions) or ollama (native /api/chat)")
+	images = flag.String("image", "", "Comma-separated image files or base64 data: URLs to attach to the query (vision models)")
+	allowNetwork = flag.Bool("allow-network", false, "Offer the http_fetch tool so the model can make HTTP requests")
+	preflight = flag.Bool("preflight", false, "Check the endpoint serves the model before running (on by default with --debug)")
+	seed = flag.Int("seed", 0, "Sampling seed for the chat request; with --count, run i uses seed+i")
+	keepAlive = flag.String("keep-alive", "", "How long Ollama keeps the model loaded after a request, e.g. 10m or -1 for until it stops (--api-style ollama and generate only)")
+	flag.IntVar(&repeatCount, "count", 1, "Run the query this many times, logging each run (rating is skipped when more than 1)")
+	flag.IntVar(&repeatCount, "n", 1, "Shorthand for --count")
+	stripThink = flag.Bool("strip-thinking", false, "Remove reasoning blocks such as <think>...</think> from answers before display and logging")
+	thinkTags = flag.String("thinking-tags", strings.Join(cli.DefaultThinkingTags, ","), "Comma-separated tag names removed by --strip-thinking")
+	httpTrace = flag.Bool("http-trace", false, "Write every model API request and response (headers and raw body, credentials redacted) to stderr")
+	maxResponse = flag.Int64("max-response-bytes", common.DefaultMaxResponseBytes, "Largest model API response to read before giving up")
+	sessionName = flag.String("session", "", "Continue the named saved conversation and save this turn to it (~/.tinypenguin/sessions)")
+	contextFile = flag.String("context-file", "", "JSON array of earlier user, assistant and tool messages to send before the query")
+	configFile = flag.String("config", cli.DefaultConfigPath(), "Config file with custom tools and the log rotation policy")
+	promptExamp = flag.Bool("prompt-examples", false, "Add worked tool call examples to the system prompt (helps some small models, costs context)")
+	timestamps = flag.Bool("timestamps", false, "Prefix progress lines with the time since the task started and the tool call step, e.g. [step 2/3, +4.3s]")
+	omitToolList = flag.Bool("no-tools-definition-in-prompt", false, "Leave the tool list out of the system prompt and rely on the structured tools field alone")
+	retryTool = flag.Bool("retry-tool-call", false, "When an action request gets a prose answer without a tool call, ask once more with a tool forced")
+	forceTools = flag.Bool("force-tools", false, "Send tool definitions even to models that appear not to support tool calling")
+	toolChoice = flag.String("tool-choice", "", "Tool use: auto, none (advice only, tools still described), required, or a tool name to force, e.g. run_commands")
+	recordFile = flag.String("record", "", "Offline: append every chat request to this file instead of calling the model")
+	replayFile = flag.String("replay", "", "Offline: answer chat requests with the ChatResponse JSON objects in this file, in order")
+	runAs = flag.String("run-as", "", "Run commands as this user unless the model names another (requires root)")
+	autoExec = flag.Bool("auto-exec", false, "Run read-only commands the model writes in its answer instead of calling a tool")
+	editCalls = flag.Bool("edit", false, "Show each tool call before it runs to run it, edit it in $EDITOR (or inline) or skip it; edits are logged")
+	safeFlag = flag.Bool("safe", false, "Confirm every command, refuse a stricter denylist, disable --auto-exec and confine file tools to the working directory (default for interactive runs)")
+	unsafeFlag = flag.Bool("unsafe", false, "Turn off the --safe default for interactive runs")
+	summarizeOut = flag.Int("summarize-output", 0, "Replace tool output larger than this many bytes with a summary from the model, the full output saved to a file (0 disables)")
+	outputDir = flag.String("output-dir", "", "Save each tool call's full output to <dir>/<timestamp>-<tool>.txt and show, log and send only its first lines")
+	auditLog = flag.String("audit-log", "", "Append every executed command, its approval decision and exit code to this file")
+}
+
+// safeMode resolves --safe: as given when set explicitly, otherwise on when
+// stdin is a terminal unless --unsafe or --yes says the model is trusted.
+// Non-interactive runs keep the previous behavior, as nobody could confirm.
+func safeMode() bool {
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "safe" {
+			explicit = true
+		}
+	})
+	if explicit {
+		if *safeFlag && (*unsafeFlag || assumeYes) {
+			log.Fatal("--safe cannot be combined with --unsafe or --yes")
+		}
+		return *safeFlag
+	}
+	return !*unsafeFlag && !assumeYes && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// taskOptionsFromFlags validates the task flags and collects them into TaskOptions
+func taskOptionsFromFlags() cli.TaskOptions {
+	if *fixedRating < 0 || *fixedRating > 5 {
+		log.Fatalf("--rate must be between 1 and 5, got %d", *fixedRating)
+	}
+	if *maxTools < 0 {
+		log.Fatalf("--max-tools must not be negative, got %d", *maxTools)
+	}
+	rating, _ := ratingEnabled()
+	if !rating && *fixedRating > 0 {
+		log.Fatal("--rate cannot be used with rating disabled (rating.enabled=false)")
+	}
+	if *maxLogSize < 0 {
+		log.Fatalf("--max-log-entries must not be negative, got %d", *maxLogSize)
+	}
+	if *apiStyle != common.APIStyleOpenAI && *apiStyle != common.APIStyleOllama {
+		log.Fatalf("--api-style must be openai or ollama, got %q", *apiStyle)
+	}
+	keepAliveValue, err := common.ParseKeepAlive(*keepAlive)
+	if err != nil {
+		log.Fatalf("--%v", err)
+	}
+	if *contextToks < 0 {
+		log.Fatalf("--context-tokens must not be negative, got %d", *contextToks)
+	}
+	if *maxOutput < 0 {
+		log.Fatalf("--max-output-bytes must not be negative, got %d", *maxOutput)
+	}
+	if *cacheTTL < 0 {
+		log.Fatalf("--cache-ttl must not be negative, got %s", *cacheTTL)
+	}
+	if *summarizeOut < 0 {
+		log.Fatalf("--summarize-output must not be negative, got %d", *summarizeOut)
+	}
+	if *summarizeOut > 0 && *outputDir != "" {
+		log.Fatal("--summarize-output cannot be combined with --output-dir; both replace large outputs")
+	}
+	if *parallelTool < 0 {
+		log.Fatalf("--parallel-tools must not be negative, got %d", *parallelTool)
+	}
+	if *maxParallel < 1 {
+		log.Fatalf("--max-parallel-commands must be at least 1, got %d", *maxParallel)
+	}
+	// Unless set, the command limit follows --parallel-tools so it keeps working
+	commandSlots, slotsSet := *maxParallel, false
+	flag.Visit(func(f *flag.Flag) {
+		slotsSet = slotsSet || f.Name == "max-parallel-commands"
+	})
+	if !slotsSet && *parallelTool > 1 {
+		commandSlots = *parallelTool
+	}
+	if *taskTimeout < 0 {
+		log.Fatalf("--task-timeout must not be negative, got %s", *taskTimeout)
+	}
+	if *maxResponse < 1 {
+		log.Fatalf("--max-response-bytes must be positive, got %d", *maxResponse)
+	}
+	var attached []common.Image
+	for _, ref := range splitList(*images) {
+		img, err := common.LoadImage(ref)
+		if err != nil {
+			log.Fatalf("--image: %v", err)
+		}
+		attached = append(attached, img)
+	}
+	var earlier []common.Message
+	if *contextFile != "" {
+		earlier, err = cli.LoadContextFile(*contextFile, *contextToks)
+		if err != nil {
+			log.Fatalf("--context-file: %v", err)
+		}
+	}
+	var thinkingTags []string
+	if *stripThink {
+		thinkingTags = splitList(*thinkTags)
+	}
+	var seedOption *int
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seedOption = seed
+		}
+	})
+
+	options := cli.TaskOptions{
+		NoRate:   *noRate || !rating,
+		Rating:   *fixedRating,
+		MaxTools: *maxTools,
+		Root:     *rootDir,
+
+		CommandWrapper: *cmdWrapper,
+		Shell:          *shellName,
+		StdinFile:      *stdinFile,
+		CacheTTL:       *cacheTTL,
+		SaveScript:     *saveScript,
+		Verbose:        verbose,
+		Quiet:          quiet,
+		Timestamps:     *timestamps,
+		EnvPassthrough: splitList(*envPass),
+		CleanEnv:       *cleanEnv,
+		Workdir:        *workdir,
+		RawOutput:      *rawOutput,
+		Plan:           *planMode,
+		Yes:            assumeYes,
+		ModelFallback:  splitList(*modelFallbk),
+		MaxOutputBytes: *maxOutput,
+		ContextTokens:  *contextToks,
+		AuditLog:       *auditLog,
+		OutputDir:      *outputDir,
+		Preflight:      preflightEnabled(),
+		APIStyle:       *apiStyle,
+		Images:         attached,
+		AllowNetwork:   *allowNetwork,
+		AutoExec:       *autoExec,
+		EditToolCalls:  *editCalls,
+		RunAs:          *runAs,
+		Seed:           seedOption,
+		KeepAlive:      keepAliveValue,
+		ThinkingTags:   thinkingTags,
+		ToolChoice:     *toolChoice,
+		ForceTools:     *forceTools,
+		PromptExamples: *promptExamp,
+		OmitToolList:   *omitToolList,
+		RetryToolCall:  *retryTool,
+		RatingContext:  *rateContext,
+		MaxLogEntries:  *maxLogSize,
+		Session:        *sessionName,
+		Context:        earlier,
+		CustomTools:    fileConfig.Tools,
+		RecordFile:     *recordFile,
+		ReplayFile:     *replayFile,
+
+		MaxResponseBytes: *maxResponse,
+		TaskTimeout:      *taskTimeout,
+		DumpPrompt:       *dumpPrompt,
+		ParallelTools:    *parallelTool,
+		SummarizeOutput:  *summarizeOut,
+
+		MaxParallelCommands: commandSlots,
+	}
+	if *httpTrace {
+		options.HTTPTrace = os.Stderr
+	}
+	if options.Safe = safeMode(); options.Safe {
+		if options.AutoExec {
+			log.Printf("--auto-exec is off in safe mode; add --unsafe to use it")
+			options.AutoExec = false
+		}
+		// Confine file tools to where the task runs unless --root says otherwise
+		if options.Root == "" {
+			options.Root = options.Workdir
+			if options.Root == "" {
+				options.Root, _ = os.Getwd()
+			}
+		}
+	}
+	return options
+}
+
+// loadConfig reads the config file and applies its log rotation policy. A
+// missing default config is not an error; a missing --config file is.
+func loadConfig() (*cli.Config, error) {
+	if *configFile == "" {
+		return &cli.Config{}, nil
+	}
+	config, err := cli.LoadConfig(*configFile)
+	if errors.Is(err, os.ErrNotExist) {
+		explicit := false
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "config" {
+				explicit = true
+			}
+		})
+		if !explicit {
+			return &cli.Config{}, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return config, cli.SetLogRotation(config.LogRotation)
+}
+
+// logTimeFlag parses a time filter flag of the log and prune commands; an
+// empty value means no filter
+func logTimeFlag(name, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := cli.ParseLogTime(value)
+	if err != nil {
+		log.Fatalf("-%s: %v", name, err)
+	}
+	return t
+}
+
+// preflightEnabled reports whether to run the startup model check. It is on
+// when requested and in debug mode, unless --preflight=false is given or the
+// run is offline.
+func preflightEnabled() bool {
+	if *recordFile != "" || *replayFile != "" || *dumpPrompt {
+		return false // Offline runs and prompt dumps never call the endpoint
+	}
+	enabled := *preflight || *debugMode
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "preflight" {
+			enabled = *preflight
+		}
+	})
+	return enabled
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 func main() {
 	flag.Parse()
+
+	logger, err := common.NewLogger(os.Stderr, *logLevel, *logFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+	if *noColor {
+		cli.DisableColor()
+	}
+	// SetDefault routes the log package through slog at info level; keep
+	// fatal errors visible regardless of --log-level
+	log.SetOutput(os.Stderr)
+	if *confirmWait < 0 {
+		log.Fatalf("--confirm-timeout must not be negative, got %s", *confirmWait)
+	}
+	cli.SetConfirmTimeout(*confirmWait)
+	if err := applyMaxLogEntriesEnv(); err != nil {
+		log.Fatal(err)
+	}
+	if err := applyRatingEnabledEnv(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Validate the config file up front so a typo fails every command, not
+	// only the ones that use the setting
+	fileConfig, err = loadConfig()
+	if err != nil {
+		log.Fatalf("--config: %v", err)
+	}
 	
 	if len(flag.Args()) == 0 {
 		fmt.Println("tinypenguin-cli - A CLI tool for AI-powered system administration")
@@ -56,9 +513,7 @@ func main() {
 		fmt.Println("  tinypenguin-cli [flags] <command> [args...]")
 		fmt.Println("")
 		fmt.Println("Commands:")
-		fmt.Println("  run <query>    - Run a task with the given query")
-		fmt.Println("  cancel <id>    - Cancel a task by ID")
-		fmt.Println("  list           - List all tasks")
+		printCommands()
 		fmt.Println("")
 		fmt.Println("Flags:")
 		flag.PrintDefaults()
@@ -68,7 +523,9 @@ func main() {
 		fmt.Println("  tinypenguin-cli run \"Install nginx package\"")
 		fmt.Println("  tinypenguin-cli run \"Create a bash script to backup files\"")
 		fmt.Println("  tinypenguin-cli --tools=false run \"Just provide advice\"")
+		fmt.Println("  tinypenguin-cli explain \"How do I find large files?\"")
 		fmt.Println("  tinypenguin-cli --debug run \"Check current users\"")
+		fmt.Println("  tinypenguin-cli --no-rate run \"Show disk usage\" < /dev/null")
 		return
 	}
 	
@@ -76,19 +533,102 @@ func main() {
 	
 	switch command {
 	case "run":
-		if len(flag.Args()) < 2 {
+		runFlags, detach := newRunFlags()
+		runFlags.Parse(flag.Args()[1:])
+		if runFlags.NArg() < 1 {
 			log.Fatal("run command requires a query argument")
 		}
+		query := runFlags.Arg(0)
+		if repeatCount < 1 {
+			log.Fatalf("--count must be at least 1, got %d", repeatCount)
+		}
+		options := taskOptionsFromFlags()
+		if *detach {
+			if options.Safe {
+				log.Fatal("A detached run cannot ask for confirmation; add --unsafe (or --yes) to run it in the background")
+			}
+			// Re-run with the same global flags, minus --detach. The
+			// background process has no stdin, so confirmations count as
+			// no unless --yes is given.
+			args := append(slices.Clone(os.Args[1:len(os.Args)-len(flag.Args())]), "run", "--", query)
+			task, err := cli.StartDetached(query, args)
+			if err != nil {
+				log.Fatalf("Failed to start task: %v", err)
+			}
+			fmt.Printf("🚀 Started %s (pid %d), output in %s\n", task.ID, task.PID, task.Output)
+			fmt.Printf("   Follow it with `tinypenguin-cli log --task %s --follow`, stop it with `tinypenguin-cli cancel %s`\n", task.ID, task.ID)
+			return
+		}
+		if err := cli.RunTaskRepeated(query, *tinyllamaURL, *model, *toolsEnabled, *debugMode, options, repeatCount); err != nil {
+			log.Printf("Failed to run task: %v", err)
+			os.Exit(cli.ExitCode(err))
+		}
+
+	case "explain":
+		if len(flag.Args()) < 2 {
+			log.Fatal("explain command requires a query argument")
+		}
 		query := flag.Arg(1)
-		if err := cli.RunTask(query, *tinyllamaURL, *model, *toolsEnabled, *debugMode); err != nil {
-			log.Fatalf("Failed to run task: %v", err)
+		// Advice only: no tools are offered and nothing in the answer is run
+		options := taskOptionsFromFlags()
+		options.NoExec = true
+		options.NoRate = true
+		if err := cli.RunTask(query, *tinyllamaURL, *model, false, *debugMode, options); err != nil {
+			log.Printf("Failed to run task: %v", err)
+			os.Exit(cli.ExitCode(err))
+		}
+		
+	case "batch":
+		if len(flag.Args()) < 2 {
+			log.Fatal("batch command requires a file argument")
+		}
+		// Batch runs advise only unless tools were explicitly requested
+		batchTools := false
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "tools" {
+				batchTools = *toolsEnabled
+			}
+		})
+		if *concurrency < 1 {
+			log.Fatalf("--concurrency must be at least 1, got %d", *concurrency)
+		}
+		options := taskOptionsFromFlags()
+		// Workers asking at once would read each other's answers from stdin
+		workers := *concurrency
+		if workers > 1 && (options.Safe || options.Plan || options.EditToolCalls) {
+			log.Printf("--concurrency %d is off while tool calls ask for confirmation; add --yes or --unsafe to run queries at once", workers)
+			workers = 1
+		}
+		if err := cli.RunBatch(flag.Arg(1), *tinyllamaURL, *model, batchTools, *debugMode, options, workers); err != nil {
+			log.Fatalf("Failed to run batch: %v", err)
+		}
+		
+	case "generate":
+		if len(flag.Args()) < 2 {
+			log.Fatal("generate command requires a prompt argument")
+		}
+		options := taskOptionsFromFlags()
+		if err := cli.RunGenerate(flag.Arg(1), *tinyllamaURL, *model, *debugMode, options, *stream); err != nil {
+			log.Printf("Failed to generate: %v", err)
+			os.Exit(cli.ExitCode(err))
 		}
 		
+	case "doctor":
+		options := taskOptionsFromFlags()
+		if err := cli.RunDoctor(*tinyllamaURL, *model, *debugMode, options); err != nil {
+			log.Printf("Doctor: %v", err)
+			os.Exit(cli.ExitCode(err))
+		}
+
 	case "cancel":
-		if *taskID == "" {
-			log.Fatal("cancel command requires --task-id flag")
+		id := *taskID
+		if id == "" {
+			id = flag.Arg(1)
 		}
-		if err := cli.CancelTask(*taskID); err != nil {
+		if id == "" {
+			log.Fatal("cancel command requires a task ID (see `tinypenguin-cli list`)")
+		}
+		if err := cli.CancelTask(id); err != nil {
 			log.Fatalf("Failed to cancel task: %v", err)
 		}
 		
@@ -97,6 +637,105 @@ func main() {
 			log.Fatalf("Failed to list tasks: %v", err)
 		}
 		
+	case "log":
+		logFlags, args := newLogFlags()
+		logFlags.Parse(flag.Args()[1:])
+		if *args.limit < 0 {
+			log.Fatalf("-n must not be negative, got %d", *args.limit)
+		}
+		opts := cli.LogViewOptions{Limit: *args.limit, Tool: *args.tool, Status: *args.status, Since: logTimeFlag("since", *args.since), JSON: *args.asJSON, Follow: *args.follow, Task: *args.task}
+		if err := cli.ShowLog(opts); err != nil {
+			log.Fatalf("Failed to show log: %v", err)
+		}
+		
+	case "prune":
+		pruneFlags, args := newPruneFlags()
+		pruneFlags.Parse(flag.Args()[1:])
+		if *args.minRating < 0 || *args.minRating > 5 {
+			log.Fatalf("-min-rating must be between 1 and 5, got %d", *args.minRating)
+		}
+		opts := cli.PruneOptions{
+			MinRating:   *args.minRating,
+			Since:       logTimeFlag("since", *args.since),
+			Before:      logTimeFlag("before", *args.before),
+			SuccessOnly: *args.successOnly,
+			DryRun:      *args.dryRun,
+			Yes:         assumeYes,
+		}
+		if err := cli.PruneLog(opts); err != nil {
+			log.Fatalf("Failed to prune log: %v", err)
+		}
+
+	case "replay":
+		if len(flag.Args()) < 2 {
+			log.Fatal("replay command requires a log entry number")
+		}
+		index, err := strconv.Atoi(flag.Arg(1))
+		if err != nil || index < 1 {
+			log.Fatalf("Invalid log entry number: %s", flag.Arg(1))
+		}
+		if err := cli.ReplayLogEntry(index, taskOptionsFromFlags()); err != nil {
+			log.Printf("Failed to replay: %v", err)
+			os.Exit(cli.ExitCode(err))
+		}
+
+	case "log-diff":
+		if len(flag.Args()) < 3 {
+			log.Fatal("log-diff command requires an old and a new log file")
+		}
+		if err := cli.DiffLogs(flag.Arg(1), flag.Arg(2)); err != nil {
+			log.Fatalf("Failed to diff logs: %v", err)
+		}
+
+	case "export-script":
+		if len(flag.Args()) < 2 {
+			log.Fatal("export-script command requires a query pattern")
+		}
+		if err := cli.ExportScript(flag.Arg(1)); err != nil {
+			log.Fatalf("Failed to export script: %v", err)
+		}
+
+	case "completion":
+		if err := writeCompletion(os.Stdout, flag.Arg(1)); err != nil {
+			log.Fatal(err)
+		}
+
+	case "config":
+		printEffectiveConfig()
+
+	case "sessions":
+		switch flag.Arg(1) {
+		case "", "list":
+			if err := cli.ListSessions(); err != nil {
+				log.Fatalf("Failed to list sessions: %v", err)
+			}
+		case "delete":
+			if len(flag.Args()) < 3 {
+				log.Fatal("sessions delete requires a session name")
+			}
+			if err := cli.DeleteSession(flag.Arg(2)); err != nil {
+				log.Fatalf("Failed to delete session: %v", err)
+			}
+		default:
+			log.Fatalf("Unknown sessions command: %s (use list or delete)", flag.Arg(1))
+		}
+
+	case "review":
+		if rating, source := ratingEnabled(); !rating {
+			log.Fatalf("Rating is disabled (%s), so there is nothing to review", source)
+		}
+		limit := 10
+		if len(flag.Args()) >= 2 {
+			n, err := strconv.Atoi(flag.Arg(1))
+			if err != nil || n < 0 {
+				log.Fatalf("Invalid review count: %s", flag.Arg(1))
+			}
+			limit = n
+		}
+		if err := cli.ReviewLog(limit); err != nil {
+			log.Fatalf("Failed to review log: %v", err)
+		}
+		
 	default:
 		log.Fatalf("Unknown command: %s", command)
 	}
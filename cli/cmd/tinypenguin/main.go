@@ -2,34 +2,160 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 
+	"example.com/tinypenguin/pkg/cli"
+	"example.com/tinypenguin/pkg/common"
 	pb "example.com/tinypenguin/pkg/pb"
 )
 
 var (
-	port = flag.Int("port", 50051, "The server port")
+	port        = flag.Int("port", 50051, "The server port")
+	logLevel    = flag.String("log-level", "info", "Log level: debug, info, warn or error")
+	logFormat   = flag.String("log-format", "text", "Log format: text or json")
+	metricsPort = flag.Int("metrics-port", 0, "Serve Prometheus metrics on this port at /metrics (0 to disable)")
+
+	// Command policy for tasks run by the server. It is authoritative: clients
+	// cannot loosen it.
+	denyFile   = flag.String("deny-file", "", "File of extra command patterns to deny, one per line")
+	allowFile  = flag.String("allow-file", "", "File of command prefixes, one per line; only matching commands may run")
+	rootDir    = flag.String("root", "", "Restrict file tools to paths inside this directory")
+	cmdWrapper = flag.String("command-wrapper", "", "Run every command through this wrapper (e.g. \"firejail --quiet\")")
+	noTools    = flag.Bool("no-tools", false, "Never offer tools or execute anything; only return the model's answer")
+	runAs      = flag.String("run-as", "", "Run every command as this user (default: the server's user)")
+	allowUsers = flag.String("allowed-users", "", "Comma-separated users the model may run commands as besides -run-as (default: none)")
+
+	// Defaults for requests that do not pick a model, and the models they may pick
+	defaultModel  = flag.String("model", "", "Model used when a request does not name one (default $MODEL or qwen2.5-coder:3b)")
+	allowedModels = flag.String("allowed-models", "", "Comma-separated models clients may request (default: any)")
+
+	maxConcurrent = flag.Int("max-concurrent-tasks", 0, "Maximum tasks running at once (0 for unlimited)")
+	maxQueued     = flag.Int("max-queued-tasks", 10, "Tasks that may wait for a slot when all are busy; more are rejected")
 )
 
 // server is used to implement tinypenguin.TaskService
 type server struct {
 	pb.UnimplementedTaskServiceServer
+	metrics *metrics
+	store   *taskStore
+	policy  cli.TaskOptions // server command policy applied to every task
+	models  []string        // models clients may request; empty allows any
+	taskSeq atomic.Int64
+}
+
+// requestSettings resolves the model and tool setting for a request within
+// the server's bounds. Unset fields fall back to the server defaults.
+func (s *server) requestSettings(req *pb.ExecuteTaskRequest) (string, cli.TaskOptions, error) {
+	options := s.policy
+	model := *defaultModel
+	if req.Model != "" {
+		if len(s.models) > 0 && !slices.Contains(s.models, req.Model) {
+			return "", options, status.Errorf(codes.PermissionDenied, "model %q is not allowed on this server", req.Model)
+		}
+		model = req.Model
+	}
+	if req.Tools != nil {
+		if *req.Tools && options.NoExec {
+			return "", options, status.Error(codes.PermissionDenied, "tools are disabled on this server")
+		}
+		options.NoExec = !*req.Tools
+	}
+	return model, options, nil
+}
+
+// loadPolicy builds the task options every server task runs with from the
+// policy flags
+func loadPolicy() (cli.TaskOptions, error) {
+	policy := cli.TaskOptions{
+		NoRate:         true,
+		Quiet:          true,
+		Root:           *rootDir,
+		CommandWrapper: *cmdWrapper,
+		NoExec:         *noTools,
+		MaxLogEntries:  cli.DefaultMaxLogEntries,
+		RunAs:          *runAs,
+		// Non-nil even when empty, so the model cannot pick another user
+		AllowedUsers: append([]string{}, splitList(*allowUsers)...),
+	}
+	if *denyFile != "" {
+		patterns, err := cli.LoadPatternFile(*denyFile)
+		if err != nil {
+			return policy, fmt.Errorf("--deny-file: %w", err)
+		}
+		policy.DenyPatterns = patterns
+	}
+	if *allowFile != "" {
+		patterns, err := cli.LoadPatternFile(*allowFile)
+		if err != nil {
+			return policy, fmt.Errorf("--allow-file: %w", err)
+		}
+		if len(patterns) == 0 {
+			return policy, fmt.Errorf("--allow-file: %s lists no commands", *allowFile)
+		}
+		policy.AllowPatterns = patterns
+	}
+	return policy, nil
+}
+
+// taskStatuses maps a task outcome to its stored status
+var taskStatuses = map[string]pb.TaskStatus{
+	"completed": pb.TaskStatus_TASK_STATUS_COMPLETED,
+	"cancelled": pb.TaskStatus_TASK_STATUS_CANCELLED,
+	"failed":    pb.TaskStatus_TASK_STATUS_FAILED,
 }
 
 // ExecuteTask implements tinypenguin.TaskService.ExecuteTask
 func (s *server) ExecuteTask(req *pb.ExecuteTaskRequest, stream pb.TaskService_ExecuteTaskServer) error {
-	log.Printf("Received task request: %s", req.Query)
+	slog.Info("received task request", "query", req.Query, "model", req.Model)
+
+	model, options, err := s.requestSettings(req)
+	if err != nil {
+		return err
+	}
+
+	taskID := fmt.Sprintf("task-%d-%d", os.Getpid(), s.taskSeq.Add(1))
+	release, err := s.store.acquire(stream.Context(), taskID, req.Query, func() {
+		slog.Info("task queued", "task_id", taskID)
+		stream.Send(&pb.ExecuteTaskResponse{
+			Response: &pb.ExecuteTaskResponse_TaskOutput{
+				TaskOutput: &pb.TaskOutput{Output: "Queued: waiting for a free task slot"},
+			},
+		})
+	})
+	if err != nil {
+		if status.Code(err) == codes.ResourceExhausted {
+			s.metrics.taskRejected()
+			slog.Warn("task rejected", "task_id", taskID, "error", err)
+		}
+		return err
+	}
+
+	start := time.Now()
+	s.metrics.taskStarted()
+	outcome := "failed"
+	defer func() {
+		s.metrics.taskFinished(outcome, time.Since(start))
+		release(taskStatuses[outcome])
+	}()
 	
 	// Create task started response
 	taskStarted := &pb.TaskStarted{
-		TaskId: "task-" + fmt.Sprintf("%d", os.Getpid()),
+		TaskId: taskID,
 	}
 	
 	response := &pb.ExecuteTaskResponse{
@@ -41,13 +167,51 @@ func (s *server) ExecuteTask(req *pb.ExecuteTaskRequest, stream pb.TaskService_E
 	if err := stream.Send(response); err != nil {
 		return err
 	}
-	
-	return nil
+
+	// Stream every tool result back to the client as it happens
+	options.OnToolResult = func(tool string, result cli.TaskResponse) {
+		s.metrics.toolExecuted(tool, result.Status)
+		output := fmt.Sprintf("%s: %s - %s", tool, result.Status, result.Message)
+		if result.Output != "" {
+			output += "\n" + result.Output
+		}
+		stream.Send(&pb.ExecuteTaskResponse{
+			Response: &pb.ExecuteTaskResponse_TaskOutput{
+				TaskOutput: &pb.TaskOutput{Output: output},
+			},
+		})
+	}
+
+	manager, err := cli.NewTaskManagerWithDefaults("", model, !options.NoExec, false, options)
+	if err != nil {
+		return err
+	}
+
+	taskErr := manager.ExecuteTask(stream.Context(), req.Query)
+	switch {
+	case errors.Is(taskErr, cli.ErrCancelled) || stream.Context().Err() != nil:
+		outcome = "cancelled"
+		return stream.Context().Err()
+	case taskErr != nil:
+		slog.Warn("task failed", "task_id", taskStarted.TaskId, "error", taskErr)
+		return stream.Send(&pb.ExecuteTaskResponse{
+			Response: &pb.ExecuteTaskResponse_TaskError{
+				TaskError: &pb.TaskError{Error: taskErr.Error()},
+			},
+		})
+	}
+
+	outcome = "completed"
+	return stream.Send(&pb.ExecuteTaskResponse{
+		Response: &pb.ExecuteTaskResponse_TaskCompleted{
+			TaskCompleted: &pb.TaskCompleted{Result: "Task completed"},
+		},
+	})
 }
 
 // CancelTask implements tinypenguin.TaskService.CancelTask
 func (s *server) CancelTask(ctx context.Context, req *pb.CancelTaskRequest) (*pb.CancelTaskResponse, error) {
-	log.Printf("Received cancel request for task: %s", req.TaskId)
+	slog.Info("received cancel request", "task_id", req.TaskId)
 	
 	return &pb.CancelTaskResponse{
 		Success: true,
@@ -56,33 +220,79 @@ func (s *server) CancelTask(ctx context.Context, req *pb.CancelTaskRequest) (*pb
 
 // ListTasks implements tinypenguin.TaskService.ListTasks
 func (s *server) ListTasks(ctx context.Context, req *pb.ListTasksRequest) (*pb.ListTasksResponse, error) {
-	log.Printf("Received list tasks request")
-	
-	// Return empty task list for now
+	slog.Info("received list tasks request")
+
+	tasks, next, err := s.store.list(int(req.PageSize), req.PageToken)
+	if err != nil {
+		return nil, err
+	}
 	return &pb.ListTasksResponse{
-		Tasks:          []*pb.Task{},
-		NextPageToken:  "",
+		Tasks:         tasks,
+		NextPageToken: next,
 	}, nil
 }
 
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func main() {
 	flag.Parse()
+
+	logger, err := common.NewLogger(os.Stderr, *logLevel, *logFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+	// SetDefault routes the log package through slog at info level; keep
+	// fatal errors visible regardless of --log-level
+	log.SetOutput(os.Stderr)
 	
 	lis, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", *port))
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		slog.Error("failed to listen", "error", err)
+		os.Exit(1)
 	}
 	
+	policy, err := loadPolicy()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Tasks must never wait for input on the server's terminal: with stdin
+	// closed every confirmation is declined
+	if devNull, err := os.Open(os.DevNull); err == nil {
+		os.Stdin = devNull
+	}
+
+	// Validate the root and wrapper once at startup rather than per task
+	if _, err := cli.NewTaskManagerWithDefaults("", "", !*noTools, false, policy); err != nil {
+		log.Fatal(err)
+	}
+
+	store := newTaskStore(*maxConcurrent, *maxQueued)
+	m := newMetrics(store)
+	if *metricsPort != 0 {
+		go serveMetrics(*metricsPort, m)
+	}
+
 	s := grpc.NewServer()
-	pb.RegisterTaskServiceServer(s, &server{})
+	pb.RegisterTaskServiceServer(s, &server{metrics: m, store: store, policy: policy, models: splitList(*allowedModels)})
 	
 	// Register reflection service on gRPC server.
 	reflection.Register(s)
 	
-	log.Printf("tinypenguin server listening at %v", lis.Addr())
+	slog.Info("tinypenguin server listening", "addr", lis.Addr().String())
 	
 	// Start the server
 	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+		slog.Error("failed to serve", "error", err)
+		os.Exit(1)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// taskDurationBuckets are the histogram upper bounds for task durations, in seconds
+var taskDurationBuckets = []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// metrics collects server counters and exports them in the Prometheus text
+// exposition format
+type metrics struct {
+	mu    sync.Mutex
+	store *taskStore // source of the running and queued gauges
+
+	tasks map[string]uint64    // by outcome: started, completed, cancelled, failed or rejected
+	tools map[[2]string]uint64 // by tool name and result status
+
+	durationCounts []uint64 // per bucket, not cumulative
+	durationSum    float64
+	durationCount  uint64
+}
+
+func newMetrics(store *taskStore) *metrics {
+	return &metrics{
+		store:          store,
+		tasks:          make(map[string]uint64),
+		tools:          make(map[[2]string]uint64),
+		durationCounts: make([]uint64, len(taskDurationBuckets)),
+	}
+}
+
+// taskStarted counts a task that was accepted
+func (m *metrics) taskStarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tasks["started"]++
+}
+
+// taskRejected counts a task turned away because the queue was full
+func (m *metrics) taskRejected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tasks["rejected"]++
+}
+
+// taskFinished counts a task outcome (completed, cancelled or failed) and
+// records how long the task ran
+func (m *metrics) taskFinished(outcome string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tasks[outcome]++
+
+	seconds := d.Seconds()
+	m.durationSum += seconds
+	m.durationCount++
+	for i, bound := range taskDurationBuckets {
+		if seconds <= bound {
+			m.durationCounts[i]++
+			break
+		}
+	}
+}
+
+// toolExecuted counts a tool execution by tool name and result status
+func (m *metrics) toolExecuted(tool, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tools[[2]string{tool, status}]++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	for _, outcome := range []string{"started", "completed", "cancelled", "failed", "rejected"} {
+		name := fmt.Sprintf("tinypenguin_tasks_%s_total", outcome)
+		fmt.Fprintf(&b, "# HELP %s Number of tasks %s.\n# TYPE %s counter\n%s %d\n", name, outcome, name, name, m.tasks[outcome])
+	}
+
+	running, queued := m.store.depth()
+	b.WriteString("# HELP tinypenguin_tasks_running Number of tasks currently running.\n# TYPE tinypenguin_tasks_running gauge\n")
+	fmt.Fprintf(&b, "tinypenguin_tasks_running %d\n", running)
+	b.WriteString("# HELP tinypenguin_tasks_queued Number of tasks waiting for a free slot.\n# TYPE tinypenguin_tasks_queued gauge\n")
+	fmt.Fprintf(&b, "tinypenguin_tasks_queued %d\n", queued)
+
+	b.WriteString("# HELP tinypenguin_tool_executions_total Number of tool executions by tool and status.\n")
+	b.WriteString("# TYPE tinypenguin_tool_executions_total counter\n")
+	keys := make([][2]string, 0, len(m.tools))
+	for k := range m.tools {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		fmt.Fprintf(&b, "tinypenguin_tool_executions_total{tool=%q,status=%q} %d\n", k[0], k[1], m.tools[k])
+	}
+
+	b.WriteString("# HELP tinypenguin_task_duration_seconds Task execution time.\n")
+	b.WriteString("# TYPE tinypenguin_task_duration_seconds histogram\n")
+	var cumulative uint64
+	for i, bound := range taskDurationBuckets {
+		cumulative += m.durationCounts[i]
+		fmt.Fprintf(&b, "tinypenguin_task_duration_seconds_bucket{le=\"%g\"} %d\n", bound, cumulative)
+	}
+	fmt.Fprintf(&b, "tinypenguin_task_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.durationCount)
+	fmt.Fprintf(&b, "tinypenguin_task_duration_seconds_sum %g\n", m.durationSum)
+	fmt.Fprintf(&b, "tinypenguin_task_duration_seconds_count %d\n", m.durationCount)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprint(w, b.String())
+}
+
+// serveMetrics exposes /metrics on its own HTTP listener
+func serveMetrics(port int, m *metrics) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	addr := fmt.Sprintf("localhost:%d", port)
+	slog.Info("metrics listening", "addr", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		slog.Error("metrics listener failed", "error", err)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "example.com/tinypenguin/pkg/pb"
+)
+
+// maxStoredTasks bounds how many tasks the store remembers; the oldest
+// finished tasks are forgotten first
+const maxStoredTasks = 1000
+
+// taskStore tracks the tasks the server has accepted and bounds how many run
+// at once. Tasks beyond the limit wait in a queue of bounded length.
+type taskStore struct {
+	mu    sync.Mutex
+	tasks map[string]*pb.Task
+	order []string // task IDs, oldest first
+
+	slots     chan struct{} // one token per running task; nil means unlimited
+	maxQueued int
+	queued    int
+	running   int
+}
+
+func newTaskStore(maxConcurrent, maxQueued int) *taskStore {
+	store := &taskStore{
+		tasks:     make(map[string]*pb.Task),
+		maxQueued: maxQueued,
+	}
+	if maxConcurrent > 0 {
+		store.slots = make(chan struct{}, maxConcurrent)
+	}
+	return store
+}
+
+// acquire registers a task and waits for a free slot to run it. It fails
+// with ResourceExhausted when the queue is full, or with the context error if
+// the client goes away while waiting. onQueued is called if the task has to
+// wait. The returned release function must be called with the final status.
+func (t *taskStore) acquire(ctx context.Context, id, query string, onQueued func()) (func(pb.TaskStatus), error) {
+	t.mu.Lock()
+	task := &pb.Task{TaskId: id, Query: query, CreatedAt: timestamppb.Now()}
+
+	if t.slots != nil {
+		select {
+		case t.slots <- struct{}{}:
+		default:
+			if t.queued >= t.maxQueued {
+				t.mu.Unlock()
+				return nil, status.Errorf(codes.ResourceExhausted,
+					"server is busy: %d tasks running and %d queued", cap(t.slots), t.queued)
+			}
+			task.Status = pb.TaskStatus_TASK_STATUS_QUEUED
+			t.queued++
+			t.addLocked(task)
+			t.mu.Unlock()
+
+			onQueued()
+			select {
+			case t.slots <- struct{}{}:
+			case <-ctx.Done():
+				t.mu.Lock()
+				t.queued--
+				task.Status = pb.TaskStatus_TASK_STATUS_CANCELLED
+				t.mu.Unlock()
+				return nil, ctx.Err()
+			}
+
+			t.mu.Lock()
+			t.queued--
+		}
+	}
+
+	if task.Status != pb.TaskStatus_TASK_STATUS_QUEUED {
+		t.addLocked(task)
+	}
+	task.Status = pb.TaskStatus_TASK_STATUS_RUNNING
+	t.running++
+	t.mu.Unlock()
+
+	release := func(final pb.TaskStatus) {
+		t.mu.Lock()
+		task.Status = final
+		t.running--
+		t.mu.Unlock()
+		if t.slots != nil {
+			<-t.slots
+		}
+	}
+	return release, nil
+}
+
+// addLocked records a task, forgetting the oldest finished task when full
+func (t *taskStore) addLocked(task *pb.Task) {
+	if len(t.order) >= maxStoredTasks {
+		for i, id := range t.order {
+			s := t.tasks[id].Status
+			if s != pb.TaskStatus_TASK_STATUS_RUNNING && s != pb.TaskStatus_TASK_STATUS_QUEUED {
+				delete(t.tasks, id)
+				t.order = append(t.order[:i], t.order[i+1:]...)
+				break
+			}
+		}
+	}
+	t.tasks[task.TaskId] = task
+	t.order = append(t.order, task.TaskId)
+}
+
+// depth returns the number of running and queued tasks
+func (t *taskStore) depth() (running, queued int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.running, t.queued
+}
+
+// list returns a page of tasks, newest first. The page token is the offset
+// of the next page.
+func (t *taskStore) list(pageSize int, pageToken string) ([]*pb.Task, string, error) {
+	offset := 0
+	if pageToken != "" {
+		n, err := strconv.Atoi(pageToken)
+		if err != nil || n < 0 {
+			return nil, "", status.Errorf(codes.InvalidArgument, "invalid page token %q", pageToken)
+		}
+		offset = n
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var tasks []*pb.Task
+	for i := len(t.order) - 1 - offset; i >= 0; i-- {
+		if pageSize > 0 && len(tasks) == pageSize {
+			return tasks, strconv.Itoa(offset + len(tasks)), nil
+		}
+		task := t.tasks[t.order[i]]
+		tasks = append(tasks, &pb.Task{
+			TaskId:    task.TaskId,
+			Query:     task.Query,
+			Status:    task.Status,
+			CreatedAt: task.CreatedAt,
+		})
+	}
+	return tasks, "", nil
+}
@@ -8,6 +8,7 @@ replace example.com/tinypenguin => ./
 
 require (
 	github.com/joho/godotenv v1.5.1
+	golang.org/x/term v0.33.0
 	google.golang.org/grpc v1.76.0
 	google.golang.org/protobuf v1.36.6
 )
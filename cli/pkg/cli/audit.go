@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+)
+
+// AuditEntry is one line of the audit log. Unlike the training log it is
+// never redacted, rewritten or rotated.
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	User      string    `json:"user"`
+	Cwd       string    `json:"cwd"`
+	Model     string    `json:"model"`
+	Command   string    `json:"command"`
+	Decision  string    `json:"decision"` // allowed, denied or confirmed
+	ExitCode  *int      `json:"exit_code,omitempty"`
+	Status    string    `json:"status"`
+}
+
+var auditMu sync.Mutex
+
+// audit appends an entry to the audit log, if one is configured. Failures are
+// reported but never stop the task.
+func (tm *TaskManager) audit(command, cwd, decision string, exitCode *int, status string) {
+	if tm.options.AuditLog == "" {
+		return
+	}
+
+	entry := AuditEntry{
+		Timestamp: time.Now(),
+		User:      currentUser(),
+		Cwd:       cwd,
+		Model:     tm.model,
+		Command:   command,
+		Decision:  decision,
+		ExitCode:  exitCode,
+		Status:    status,
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		slog.Error("failed to encode audit entry", "error", err)
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	f, err := os.OpenFile(tm.options.AuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Failed to open audit log: %v\n", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Failed to write audit log: %v\n", err)
+	}
+}
+
+// commandDecision describes how a command that passed the safety check was
+// approved: explicitly by the user in plan mode, or automatically
+func (tm *TaskManager) commandDecision() string {
+	if tm.options.Plan {
+		return "confirmed"
+	}
+	return "allowed"
+}
+
+// currentUser returns the login name of the user running the CLI
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchResult records the outcome of a single query in a batch run
+type batchResult struct {
+	Query    string
+	Err      error
+	Duration time.Duration
+}
+
+// readBatchQueries reads queries from a file. Each non-empty line is a query,
+// or a JSON object with a "query" field for JSONL files. Lines starting with
+// # are ignored.
+func readBatchQueries(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var queries []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "{") {
+			var entry struct {
+				Query string `json:"query"`
+			}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			if entry.Query == "" {
+				return nil, fmt.Errorf("line %d: missing \"query\" field", lineNum)
+			}
+			line = entry.Query
+		}
+		queries = append(queries, line)
+	}
+	return queries, scanner.Err()
+}
+
+// RunBatch runs every query in the file unattended and prints an aggregated
+// report. Rating is always skipped. With concurrency > 1 several queries run
+// at once and their output is interleaved.
+func RunBatch(path string, tinyllamaURL string, model string, toolsEnabled, debugMode bool, options TaskOptions, concurrency int) error {
+	queries, err := readBatchQueries(path)
+	if err != nil {
+		return fmt.Errorf("failed to read batch file: %w", err)
+	}
+	if len(queries) == 0 {
+		return fmt.Errorf("no queries found in %s", path)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	options.NoRate = true
+	manager, err := NewTaskManagerWithDefaults(tinyllamaURL, model, toolsEnabled, debugMode, options)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	if options.Preflight {
+		if err := manager.preflight(ctx); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("📦 Running %d queries from %s (concurrency %d, tools %v)\n", len(queries), path, concurrency, toolsEnabled)
+
+	// Each running query gets a manager of its own; they share the limits
+	// and the script, see TaskManager.worker
+	workers := make(chan *TaskManager, concurrency)
+	for range concurrency {
+		workers <- manager.worker()
+	}
+
+	results := make([]batchResult, len(queries))
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		if ctx.Err() != nil {
+			results[i] = batchResult{Query: query, Err: fmt.Errorf("not run: batch cancelled")}
+			continue
+		}
+		worker := <-workers
+		wg.Add(1)
+		go func(i int, query string) {
+			defer wg.Done()
+			defer func() { workers <- worker }()
+			start := time.Now()
+			fmt.Printf("\n━━━ [%d/%d] %s\n", i+1, len(queries), query)
+			err := worker.ExecuteTask(ctx, query)
+			results[i] = batchResult{Query: query, Err: err, Duration: time.Since(start)}
+		}(i, query)
+	}
+	wg.Wait()
+
+	if failed := printBatchReport(results); failed > 0 {
+		return fmt.Errorf("%d of %d queries failed", failed, len(results))
+	}
+	return nil
+}
+
+// printBatchReport prints a per-query table and totals and returns the
+// number of failed queries
+func printBatchReport(results []batchResult) int {
+	var total time.Duration
+	failed := 0
+
+	fmt.Printf("\n📊 Batch report\n")
+	for i, r := range results {
+		status := colorize(ansiGreen, "✅ ok")
+		if r.Err != nil {
+			status = colorize(ansiRed, "❌ "+r.Err.Error())
+			failed++
+		}
+		total += r.Duration
+		query := r.Query
+		if len(query) > 50 {
+			query = query[:47] + "..."
+		}
+		fmt.Printf("  %3d. %-50s %7.1fs  %s\n", i+1, query, r.Duration.Seconds(), status)
+	}
+	fmt.Printf("\n  Total: %d queries, %d succeeded, %d failed, %.1fs task time\n",
+		len(results), len(results)-failed, failed, total.Seconds())
+	return failed
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// repeatClient answers every chat request with the same response
+type repeatClient struct {
+	scriptedClient
+}
+
+func (c *repeatClient) Chat(ctx context.Context, req *common.ChatRequest) (*common.ChatResponse, error) {
+	return &common.ChatResponse{Choices: []common.Choice{{Message: c.response}}}, nil
+}
+
+func TestWorkersRunTasksConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	client := &repeatClient{scriptedClient{response: common.Message{
+		Role: "assistant",
+		ToolCalls: []common.ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: common.FunctionCall{Name: "run_commands", Arguments: `{"command": "echo hello"}`},
+		}},
+	}}}
+	script := filepath.Join(dir, "batch.sh")
+	manager := NewTaskManager("", "test-model", true, false, TaskOptions{
+		NoRate:              true,
+		Quiet:               true,
+		Timestamps:          true,
+		MaxParallelCommands: 2,
+		SaveScript:          script,
+	})
+	manager.tinyllamaClient = client
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = manager.worker().ExecuteTask(context.Background(), fmt.Sprintf("query %d", i))
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("task %d: %v", i, err)
+		}
+	}
+	steps, err := manager.script.save()
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if steps != 4 {
+		t.Errorf("the shared script has %d steps, want 4", steps)
+	}
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Tool calling support of a model, as reported by detectToolSupport
+const (
+	ToolSupportYes     = "supported"
+	ToolSupportNo      = "unsupported"
+	ToolSupportUnknown = "unknown"
+)
+
+// toolModelFamilies maps model families (the name before the ":tag") to
+// whether they were trained for function calling. Names are matched by
+// prefix, so "llama3.1" covers "llama3.1:8b" and "llama3.1-instruct"; the
+// longest matching prefix wins.
+var toolModelFamilies = map[string]bool{
+	"qwen2": true, "qwen2.5": true, "qwen2.5-coder": true, "qwen3": true,
+	"llama3.1": true, "llama3.2": true, "llama3.3": true, "llama4": true,
+	"mistral": true, "mistral-nemo": true, "mistral-small": true, "mixtral": true,
+	"command-r": true, "firefunction": true, "hermes3": true, "granite3": true,
+	"smollm2": true, "phi4-mini": true, "nemotron": true, "gpt-oss": true,
+	"functiongemma": true, "devstral": true,
+
+	"tinyllama": false, "llama2": false, "llama3": false, "codellama": false,
+	"gemma": false, "phi": false, "phi3": false, "phi4": false,
+	"deepseek-coder": false, "deepseek-r1": false, "starcoder": false,
+	"orca-mini": false, "vicuna": false, "falcon": false, "dolphin": false,
+	"qwen": false, "qwen2.5vl": false, "llava": false, "moondream": false,
+}
+
+// knownToolSupport looks a model up in toolModelFamilies. Base and text
+// completion variants never call tools, whatever their family.
+func knownToolSupport(model string) (string, string) {
+	name := strings.ToLower(path.Base(model)) // Drop registry and namespace, e.g. hf.co/org/
+	family, tag, _ := strings.Cut(name, ":")
+	if strings.Contains(tag, "text") || strings.Contains(tag, "base") || strings.HasSuffix(family, "-base") {
+		return ToolSupportNo, "base/text model"
+	}
+
+	match := ""
+	for prefix := range toolModelFamilies {
+		if strings.HasPrefix(family, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	switch {
+	case match == "":
+		return ToolSupportUnknown, "not in the known model table"
+	case toolModelFamilies[match]:
+		return ToolSupportYes, fmt.Sprintf("known %s family", match)
+	}
+	return ToolSupportNo, fmt.Sprintf("known %s family", match)
+}
+
+// capabilityProber is implemented by clients that can ask the server what a
+// model supports (Ollama's /api/show)
+type capabilityProber interface {
+	ModelCapabilities(ctx context.Context, model string) ([]string, error)
+}
+
+// detectToolSupport reports whether the model is likely to honour the tools
+// field, and where that answer came from. Models the table knows to support
+// tools are trusted without a request; otherwise the server is asked, and
+// the table is the fallback when it cannot tell.
+func (tm *TaskManager) detectToolSupport(ctx context.Context) (string, string) {
+	support, reason := knownToolSupport(tm.model)
+	if support == ToolSupportYes {
+		return support, reason
+	}
+
+	if prober, ok := tm.tinyllamaClient.(capabilityProber); ok {
+		ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+		defer cancel()
+		capabilities, err := prober.ModelCapabilities(ctx, tm.model)
+		switch {
+		case err != nil:
+			tm.verbosef("Capability probe failed: %v", err)
+		case capabilities == nil:
+			tm.verbosef("Capability probe: the server does not report capabilities")
+		case slices.Contains(capabilities, "tools"):
+			return ToolSupportYes, "reported by the server"
+		default:
+			return ToolSupportNo, "server reports capabilities " + strings.Join(capabilities, ", ")
+		}
+	}
+	return support, reason
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheableCommands always print the same thing until something on the
+// system changes, unlike e.g. date, ps or df. They are matched as whole
+// commands: "hostname foo" or "hostnamectl set-hostname foo" changes the
+// system and must run every time.
+var cacheableCommands = []string{
+	"pwd", "whoami", "arch", "nproc", "hostname", "hostnamectl",
+	"hostnamectl status", "cat /etc/os-release", "cat /etc/passwd",
+	"cat /etc/group",
+}
+
+// cacheableLookups only print something whatever arguments follow, such as
+// the options of uname or the names looked up by which
+var cacheableLookups = []string{
+	"id", "groups", "uname", "lsb_release", "getent passwd", "getent group",
+	"which", "command -v",
+}
+
+// commandCache keeps the results of cacheable commands for --cache-ttl. It is
+// cleared whenever a tool call that may change the system runs.
+type commandCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedCommand
+}
+
+type cachedCommand struct {
+	result TaskResponse
+	at     time.Time
+}
+
+// cacheableCommand reports whether a command's result may be reused: one of
+// cacheableCommands, or one of cacheableLookups with its arguments, with
+// nothing that could redirect, chain or substitute
+func cacheableCommand(command string) bool {
+	if strings.ContainsAny(command, "<>;&|`$*?\n") {
+		return false
+	}
+	command = strings.Join(strings.Fields(command), " ")
+	for _, c := range cacheableCommands {
+		if command == c {
+			return true
+		}
+	}
+	for _, c := range cacheableLookups {
+		if command == c || strings.HasPrefix(command, c+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// commandCacheKey identifies a command run; the directory and user matter
+// for pwd, whoami and relative paths
+func commandCacheKey(command, dir, user string) string {
+	return user + "\x00" + dir + "\x00" + strings.TrimSpace(command)
+}
+
+// lookup returns a result cached less than ttl ago and its age
+func (c *commandCache) lookup(key string, ttl time.Duration) (TaskResponse, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Since(entry.at) >= ttl {
+		return TaskResponse{}, 0, false
+	}
+	return entry.result, time.Since(entry.at), true
+}
+
+// store caches a successful result
+func (c *commandCache) store(key string, result TaskResponse) {
+	if result.Status != "success" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedCommand)
+	}
+	c.entries[key] = cachedCommand{result: result, at: time.Now()}
+}
+
+// clear forgets every cached result
+func (c *commandCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
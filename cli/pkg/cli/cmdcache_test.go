@@ -0,0 +1,30 @@
+package cli
+
+import "testing"
+
+func TestCacheableCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"pwd", true},
+		{"  uname   -r ", true},
+		{"hostnamectl", true},
+		{"hostnamectl status", true},
+		{"hostnamectl set-hostname foo", false},
+		{"hostname", true},
+		{"hostname foo", false},
+		{"which nginx", true},
+		{"getent passwd alice", true},
+		{"cat /etc/os-release", true},
+		{"cat /etc/os-release /etc/shadow", false},
+		{"pwd; rm -rf x", false},
+		{"uname -r > out", false},
+		{"date", false},
+	}
+	for _, tt := range tests {
+		if got := cacheableCommand(tt.command); got != tt.want {
+			t.Errorf("cacheableCommand(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// Classes of run_commands failures, reported in TaskResponse.ErrorKind
+const (
+	ErrorKindNotFound   = "not-found"       // The command or a file it needs does not exist
+	ErrorKindPermission = "permission"      // The command lacked the privileges it needed
+	ErrorKindTimeout    = "timeout"         // The command ran past its timeout
+	ErrorKindFailure    = "generic-failure" // Any other non-zero exit
+)
+
+// Output fragments that identify a permission failure when the exit code
+// does not
+var permissionErrors = []string{
+	"permission denied",
+	"operation not permitted",
+	"must be root",
+	"must be run as root",
+	"requires root",
+	"are not allowed to",
+}
+
+// classifyCommandError returns the exit code of a failed command, when it
+// exited, and the class of the failure. The exit code decides first (126 is
+// not executable, 127 not found, as set by bash); otherwise the output is
+// checked for the usual permission messages.
+func classifyCommandError(err error, output string) (*int, string) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrorKindNotFound
+		}
+		if errors.Is(err, fs.ErrPermission) {
+			return nil, ErrorKindPermission
+		}
+		return nil, ErrorKindFailure
+	}
+
+	code := exitErr.ExitCode()
+	switch code {
+	case 126:
+		return &code, ErrorKindPermission
+	case 127:
+		return &code, ErrorKindNotFound
+	}
+	lower := strings.ToLower(output)
+	for _, fragment := range permissionErrors {
+		if strings.Contains(lower, fragment) {
+			return &code, ErrorKindPermission
+		}
+	}
+	if strings.Contains(lower, "command not found") {
+		return &code, ErrorKindNotFound
+	}
+	return &code, ErrorKindFailure
+}
+
+// commandFailureMessage describes a failed command for the user
+func commandFailureMessage(exitCode *int, kind string) string {
+	var reason string
+	switch kind {
+	case ErrorKindNotFound:
+		reason = "command or file not found"
+	case ErrorKindPermission:
+		reason = "permission denied"
+	default:
+		reason = "the command reported an error"
+	}
+	if exitCode == nil {
+		return fmt.Sprintf("Command failed (%s)", reason)
+	}
+	return fmt.Sprintf("Command failed with exit code %d (%s)", *exitCode, reason)
+}
+
+// commandErrorMessage builds the tool message that tells the model how its
+// command failed, with advice matching the class of failure
+func commandErrorMessage(toolCall common.ToolCall, result TaskResponse) common.Message {
+	var advice string
+	switch result.ErrorKind {
+	case ErrorKindPermission:
+		advice = "It needs more privileges; retry it with sudo if running as root is appropriate."
+	case ErrorKindNotFound:
+		advice = "Install the package that provides the missing command, or use an alternative that is available."
+	case ErrorKindTimeout:
+		advice = "Use a larger timeout, or a command that finishes sooner."
+	default:
+		advice = "Check the output and suggest a corrected command."
+	}
+	content := fmt.Sprintf("Error: your %s tool call failed [%s]: %s. %s",
+		toolCall.Function.Name, result.ErrorKind, result.Message, advice)
+	if result.Output != "" {
+		content += "\nOutput:\n" + result.Output
+	}
+	return common.Message{Role: "tool", ToolCallID: toolCall.ID, Content: content}
+}
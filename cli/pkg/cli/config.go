@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config is the optional config file, ~/.tinypenguin/config.json by default
+type Config struct {
+	Tools       []CustomTool `json:"tools"`                  // Custom tools offered alongside the built-ins
+	LogRotation string       `json:"log_rotation,omitempty"` // Which entries tool_calls.log drops when full: "oldest" (default) or "rated"
+	Rating      RatingConfig `json:"rating"`
+}
+
+// RatingConfig is the rating section of the config file
+type RatingConfig struct {
+	Enabled *bool `json:"enabled,omitempty"` // false turns off rating prompts and ratings in the log; unset means enabled
+}
+
+// DefaultConfigPath returns ~/.tinypenguin/config.json
+func DefaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".tinypenguin", "config.json")
+}
+
+// LoadConfig reads and validates a config file. Unknown keys and values of
+// the wrong type are errors, so typos do not silently fall back to defaults.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %s", path, describeConfigError(err))
+	}
+	if err := validateCustomTools(config.Tools); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := validateLogRotation(config.LogRotation); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// describeConfigError rewords JSON decoding errors in terms of config keys
+func describeConfigError(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return fmt.Sprintf("%s must be %s, not %s", typeErr.Field, jsonTypeName(typeErr.Type.Kind().String()), typeErr.Value)
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return "unknown key " + field
+	}
+	return err.Error()
+}
+
+// jsonTypeName names a Go kind as the JSON type a user would write
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "slice", "array":
+		return "a list"
+	case "struct", "map":
+		return "an object"
+	case "int", "int64", "float64":
+		return "a number"
+	case "bool":
+		return "true or false"
+	}
+	return "a " + kind
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"log/slog"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// estimateTokens gives a rough token count for a message (about four
+// characters per token), including tool call names and arguments
+func estimateTokens(msg common.Message) int {
+	chars := len(msg.Role) + len(msg.Content)
+	for _, tc := range msg.ToolCalls {
+		chars += len(tc.Function.Name) + len(tc.Function.Arguments)
+	}
+	return chars/4 + 4 // small per-message overhead
+}
+
+// trimMessages drops the oldest conversation turns until the estimated token
+// count fits the budget. System messages and the final message are always
+// kept, and tool results are dropped together with the assistant message that
+// requested them so the conversation stays well formed.
+func trimMessages(messages []common.Message, budget int) []common.Message {
+	if budget <= 0 {
+		return messages
+	}
+
+	total := 0
+	for _, msg := range messages {
+		total += estimateTokens(msg)
+	}
+	if total <= budget {
+		return messages
+	}
+
+	drop := make([]bool, len(messages))
+	dropped := 0
+	for i := 0; i < len(messages)-1 && total > budget; i++ {
+		if messages[i].Role == "system" {
+			continue
+		}
+		drop[i] = true
+		total -= estimateTokens(messages[i])
+		dropped++
+
+		// Tool results without their assistant message would be rejected
+		for i+1 < len(messages)-1 && messages[i+1].Role == "tool" {
+			i++
+			drop[i] = true
+			total -= estimateTokens(messages[i])
+			dropped++
+		}
+	}
+
+	trimmed := make([]common.Message, 0, len(messages)-dropped)
+	for i, msg := range messages {
+		if !drop[i] {
+			trimmed = append(trimmed, msg)
+		}
+	}
+
+	slog.Info("trimmed conversation to fit context budget", "dropped_messages", dropped,
+		"estimated_tokens", total, "budget", budget)
+	return trimmed
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// maxContextFileBytes bounds a --context-file when no token budget is set
+const maxContextFileBytes = 1 << 20
+
+// LoadContextFile reads earlier messages for --context-file: a JSON array of
+// user, assistant and tool messages, such as the messages of a saved session.
+// Tool calls left without a result are answered as not executed. A
+// conversation larger than the token budget (or maxContextFileBytes when
+// there is none) is refused rather than trimmed, since dropping turns from
+// hand-made context would silently change what the model is told.
+func LoadContextFile(path string, budget int) ([]common.Message, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxContextFileBytes {
+		return nil, fmt.Errorf("%s is %d bytes, more than the %d a context file may hold", path, info.Size(), maxContextFileBytes)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []common.Message
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&messages); err != nil {
+		return nil, fmt.Errorf("%s is not a JSON array of messages: %v", path, err)
+	}
+	if err := validateMessages(messages); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	// Reuse the session's bookkeeping for calls the file leaves unanswered
+	earlier := &Session{Messages: messages}
+	earlier.answerPendingCalls()
+	messages = earlier.Messages
+
+	if budget > 0 {
+		tokens := 0
+		for _, msg := range messages {
+			tokens += estimateTokens(msg)
+		}
+		if tokens > budget {
+			return nil, fmt.Errorf("%s is about %d tokens, more than the context budget of %d (--context-tokens)", path, tokens, budget)
+		}
+	}
+	return messages, nil
+}
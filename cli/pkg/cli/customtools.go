@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// CustomTool is a user-defined tool from the config file. Calling it runs
+// Command with every {{arg}} placeholder replaced by the shell-quoted
+// argument, through the same checks as run_commands.
+type CustomTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"` // JSON schema of the arguments
+	Command     string                 `json:"command"`
+	Timeout     int                    `json:"timeout,omitempty"` // Seconds; the run_commands default when 0
+}
+
+var (
+	toolNamePattern    = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// validateCustomTools checks the tool definitions and that their names are
+// unique and do not shadow a built-in tool
+func validateCustomTools(tools []CustomTool) error {
+	seen := make(map[string]bool)
+	for _, tool := range builtinTools() {
+		seen[tool.Function.Name] = true
+	}
+	for _, name := range []string{"manage_package", "http_fetch"} {
+		seen[name] = true
+	}
+	for i := range tools {
+		tool := &tools[i]
+		if err := tool.validate(); err != nil {
+			return fmt.Errorf("invalid tool %q: %w", tool.Name, err)
+		}
+		if seen[tool.Name] {
+			return fmt.Errorf("invalid tool %q: name is already taken", tool.Name)
+		}
+		seen[tool.Name] = true
+	}
+	return nil
+}
+
+// validate checks the tool definition and fills in an empty parameter schema
+func (t *CustomTool) validate() error {
+	if !toolNamePattern.MatchString(t.Name) {
+		return fmt.Errorf("name must be letters, digits, _ or -")
+	}
+	if t.Command == "" {
+		return fmt.Errorf("command is required")
+	}
+	if t.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if t.Parameters == nil {
+		t.Parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	if typ, _ := t.Parameters["type"].(string); typ != "object" {
+		return fmt.Errorf(`parameters must be a JSON schema of type "object"`)
+	}
+	properties, _ := t.Parameters["properties"].(map[string]interface{})
+	for _, m := range placeholderPattern.FindAllStringSubmatch(t.Command, -1) {
+		if _, ok := properties[m[1]]; !ok {
+			return fmt.Errorf("command uses {{%s}} but the parameters do not declare it", m[1])
+		}
+	}
+	return nil
+}
+
+// definition returns the tool as offered to the model
+func (t CustomTool) definition() common.Tool {
+	return common.CreateToolDefinition(t.Name, t.Description, t.Parameters)
+}
+
+// expand substitutes the arguments into the command template. Missing
+// optional arguments become empty strings.
+func (t CustomTool) expand(arguments string) (string, error) {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", fmt.Errorf("failed to parse %s arguments: %w", t.Name, err)
+	}
+	command := placeholderPattern.ReplaceAllStringFunc(t.Command, func(placeholder string) string {
+		name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+		switch v := args[name].(type) {
+		case nil:
+			return "''"
+		case string:
+			return shellQuote(v)
+		case float64, bool:
+			return shellQuote(fmt.Sprint(v))
+		default:
+			encoded, _ := json.Marshal(v)
+			return shellQuote(string(encoded))
+		}
+	})
+	return command, nil
+}
+
+// findCustomTool returns the configured custom tool with the given name
+func (tm *TaskManager) findCustomTool(name string) (CustomTool, bool) {
+	for _, tool := range tm.options.CustomTools {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return CustomTool{}, false
+}
+
+// executeCustomTool expands the tool's command and runs it as run_commands
+// would, so the deny patterns, allowlist, sandbox and audit log all apply
+func (tm *TaskManager) executeCustomTool(ctx context.Context, tool CustomTool, arguments string) TaskResponse {
+	command, err := tool.expand(arguments)
+	if err != nil {
+		return TaskResponse{Status: "error", Message: err.Error()}
+	}
+	params := map[string]interface{}{"command": command}
+	if tool.Timeout > 0 {
+		params["timeout"] = tool.Timeout
+	}
+	runArgs, _ := json.Marshal(params)
+	return tm.executeRunCommands(ctx, string(runArgs))
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// denialMessage builds the tool message that tells the model why its call
+// was denied, so it can adjust instead of repeating the same call
+func denialMessage(toolCall common.ToolCall, result TaskResponse) common.Message {
+	return common.Message{
+		Role:       "tool",
+		ToolCallID: toolCall.ID,
+		Content: fmt.Sprintf("Error: your %s tool call was denied: %s. "+
+			"Do not retry the same call; suggest a safer approach that achieves the same goal.",
+			toolCall.Function.Name, result.Message),
+	}
+}
+
+// suggestAfterFailure reports a denied tool call, or a command that failed,
+// back to the model and shows the alternative it proposes. The alternative is
+// only displayed, never executed, so the user stays in control of what runs
+// after a failure.
+func (tm *TaskManager) suggestAfterFailure(ctx context.Context, model string, messages []common.Message, tools []common.Tool, assistant common.Message, toolCall common.ToolCall, result TaskResponse) {
+	if tm.options.Quiet || ctx.Err() != nil {
+		return
+	}
+
+	followUp := append([]common.Message{}, messages...)
+	if result.Status == "denied" {
+		followUp = append(followUp, assistant, denialMessage(toolCall, result))
+		tm.progressf("🔁 Telling the model why %s was denied...\n", toolCall.Function.Name)
+	} else {
+		followUp = append(followUp, assistant, commandErrorMessage(toolCall, result))
+		tm.progressf("🔁 Telling the model why %s failed (%s)...\n", toolCall.Function.Name, result.ErrorKind)
+	}
+	resp, _, err := tm.chat(ctx, &common.ChatRequest{
+		Model:    model,
+		Messages: followUp,
+		Tools:    tools,
+	})
+	if err != nil || len(resp.Choices) == 0 {
+		slog.Warn("no suggestion after failure", "tool", toolCall.Function.Name, "error", err)
+		return
+	}
+
+	reply := resp.Choices[0].Message
+	if len(reply.ToolCalls) == 0 && reply.Content != "" {
+		reply.ToolCalls = tm.extractToolCallsFromContent(reply.Content)
+	}
+	if len(reply.ToolCalls) == 0 {
+		if reply.Content != "" {
+			fmt.Printf("💡 Model suggests: %s\n", reply.Content)
+		}
+		return
+	}
+	fmt.Println("💡 Suggested alternative (not executed):")
+	for _, tc := range reply.ToolCalls {
+		fmt.Printf("   %s\n", tc.Function.Name)
+		fmt.Println(indent(prettyArguments(tc.Function.Arguments), "   "))
+	}
+}
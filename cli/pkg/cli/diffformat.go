@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diff formats accepted by edit_files
+const (
+	diffFormatUnified       = "unified"
+	diffFormatGit           = "git"
+	diffFormatSearchReplace = "search/replace"
+)
+
+// Markers of a search/replace block
+const (
+	searchMarker  = "<<<<<<< SEARCH"
+	dividerMarker = "======="
+	replaceMarker = ">>>>>>> REPLACE"
+)
+
+// detectDiffFormat reports which format a diff from the model is in, or ""
+// when it is none of them
+func detectDiffFormat(diff string) string {
+	var unified, git bool
+	for _, line := range strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n") {
+		line = strings.TrimRight(line, " \t")
+		switch {
+		case line == searchMarker:
+			return diffFormatSearchReplace
+		case strings.HasPrefix(line, "diff --git "), strings.HasPrefix(line, "--- a/"), strings.HasPrefix(line, "+++ b/"):
+			git = true
+		case strings.HasPrefix(line, "@@"):
+			unified = true
+		}
+	}
+	switch {
+	case git:
+		return diffFormatGit
+	case unified:
+		return diffFormatUnified
+	}
+	return ""
+}
+
+// applyDiff detects the format of diff and applies it to content. Errors name
+// the format that was tried.
+func applyDiff(content, diff string) (string, error) {
+	format := detectDiffFormat(diff)
+	var updated string
+	var err error
+	switch format {
+	case diffFormatSearchReplace:
+		updated, err = applySearchReplace(content, diff)
+	case diffFormatUnified, diffFormatGit:
+		if files := countDiffFiles(diff); files > 1 {
+			err = fmt.Errorf("it changes %d files; send one edit_files call per file", files)
+		} else {
+			updated, err = applyUnifiedDiff(content, diff)
+		}
+	default:
+		return "", fmt.Errorf("unrecognized diff format: expected a unified diff with @@ hunks or %s / %s / %s blocks",
+			searchMarker, dividerMarker, replaceMarker)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s diff: %w", format, err)
+	}
+	return updated, nil
+}
+
+// countDiffFiles returns how many "+++" file headers a unified diff has
+func countDiffFiles(diff string) int {
+	files := 0
+	inHunk := false
+	var counts hunkCounter
+	for _, line := range strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+			_, oldLines, newLines, _ := parseHunkHeader(line)
+			counts = hunkCounter{old: oldLines, new: newLines}
+		case inHunk && counts.body(line):
+			// Within the lines the @@ header counts, whatever it starts with
+		case strings.HasPrefix(line, "diff "):
+			inHunk = false
+		case strings.HasPrefix(line, "+++ ") && !inHunk:
+			files++
+		case strings.HasPrefix(line, "--- ") && inHunk:
+			// A removed line starting with "-- " looks like a header, so
+			// only a following "+++" counts as a new file
+			inHunk = false
+		}
+	}
+	return files
+}
+
+// searchReplaceBlock is one SEARCH/REPLACE pair
+type searchReplaceBlock struct {
+	search  []string
+	replace []string
+}
+
+// applySearchReplace applies <<<<<<< SEARCH / ======= / >>>>>>> REPLACE blocks
+// in order. Each search text must occur in the file; the first occurrence is
+// replaced. An empty search text appends the replacement to the file, which
+// is how a new file is created.
+func applySearchReplace(content, diff string) (string, error) {
+	blocks, err := parseSearchReplace(diff)
+	if err != nil {
+		return "", err
+	}
+
+	lines, trailingNewline := splitLines(content)
+	if content == "" {
+		trailingNewline = true
+	}
+	for i, b := range blocks {
+		if len(b.search) == 0 {
+			lines = append(lines, b.replace...)
+			continue
+		}
+		pos := findBlock(lines, b.search, 0, 0)
+		if pos < 0 {
+			return "", fmt.Errorf("block %d: the SEARCH text does not match the file (starting %q)", i+1, b.search[0])
+		}
+		updated := append([]string{}, lines[:pos]...)
+		updated = append(updated, b.replace...)
+		lines = append(updated, lines[pos+len(b.search):]...)
+	}
+
+	result := strings.Join(lines, "\n")
+	if trailingNewline && len(lines) > 0 {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// parseSearchReplace extracts the blocks, ignoring text around them such as
+// file names or code fences
+func parseSearchReplace(diff string) ([]searchReplaceBlock, error) {
+	const (
+		outside = iota
+		inSearch
+		inReplace
+	)
+	var blocks []searchReplaceBlock
+	var current searchReplaceBlock
+	state := outside
+
+	for _, line := range strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n") {
+		marker := strings.TrimRight(line, " \t")
+		switch state {
+		case outside:
+			if marker == searchMarker {
+				current = searchReplaceBlock{}
+				state = inSearch
+			}
+		case inSearch:
+			switch marker {
+			case dividerMarker:
+				state = inReplace
+			case searchMarker, replaceMarker:
+				return nil, fmt.Errorf("block %d: expected %s before %s", len(blocks)+1, dividerMarker, marker)
+			default:
+				current.search = append(current.search, line)
+			}
+		case inReplace:
+			switch marker {
+			case replaceMarker:
+				blocks = append(blocks, current)
+				state = outside
+			case searchMarker, dividerMarker:
+				return nil, fmt.Errorf("block %d: expected %s before %s", len(blocks)+1, replaceMarker, marker)
+			default:
+				current.replace = append(current.replace, line)
+			}
+		}
+	}
+
+	if state != outside {
+		return nil, fmt.Errorf("block %d is not closed with %s", len(blocks)+1, replaceMarker)
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no %s blocks found", searchMarker)
+	}
+	return blocks, nil
+}
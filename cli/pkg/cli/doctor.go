@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// RunDoctor checks the setup a task depends on: that the endpoint answers,
+// that it serves the model, and whether the model supports tool calling. It
+// prints one line per check and fails when the endpoint or model is missing.
+func RunDoctor(tinyllamaURL, model string, debugMode bool, options TaskOptions) error {
+	manager, err := NewTaskManagerWithDefaults(tinyllamaURL, model, true, debugMode, options)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	problems := 0
+	listCtx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	list, err := manager.tinyllamaClient.ListModels(listCtx)
+	cancel()
+	if err != nil {
+		printError("❌ Endpoint %s: %v\n", manager.tinyllamaClient.BaseURL(), err)
+		problems++
+	} else {
+		available := list.Names()
+		printSuccess("✅ Endpoint %s: reachable, %d model(s)\n", manager.tinyllamaClient.BaseURL(), len(available))
+		if slices.Contains(available, manager.model) {
+			printSuccess("✅ Model %s: available\n", manager.model)
+		} else {
+			printError("❌ Model %s: not served; pull it first, e.g. `ollama pull %s`\n", manager.model, manager.model)
+			problems++
+		}
+	}
+
+	switch support, reason := manager.detectToolSupport(ctx); support {
+	case ToolSupportYes:
+		printSuccess("✅ Tool calling: supported (%s)\n", reason)
+	case ToolSupportNo:
+		printWarning("⚠️  Tool calling: unsupported (%s); runs read tool calls from the answer text unless --force-tools is given\n", reason)
+	default:
+		printWarning("❓ Tool calling: unknown (%s); tools are sent, and commands in the answer are used if none are called\n", reason)
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("doctor found %d problem(s)", problems)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// dumpPrompt prints what a chat request would send: the assembled system
+// prompt as plain text, the rest of the messages array as JSON and the
+// offered tools. Attached images are left out of the messages and only
+// counted.
+func dumpPrompt(req *common.ChatRequest) {
+	messages := req.Messages
+	if len(messages) > 0 && messages[0].Role == "system" {
+		fmt.Printf("=== System prompt ===\n%s\n\n", messages[0].Content)
+		messages = messages[1:]
+	}
+
+	images := 0
+	shown := make([]common.Message, len(messages))
+	for i, msg := range messages {
+		images += len(msg.Images)
+		msg.Images = nil
+		shown[i] = msg
+	}
+	data, _ := json.MarshalIndent(shown, "", "  ")
+	fmt.Printf("=== Messages (%d after the system prompt) ===\n%s\n", len(shown), data)
+	if images > 0 {
+		fmt.Printf("(%d image(s) attached, not shown)\n", images)
+	}
+
+	names := make([]string, len(req.Tools))
+	for i, tool := range req.Tools {
+		names[i] = tool.Function.Name
+	}
+	if len(names) == 0 {
+		names = []string{"none"}
+	}
+	fmt.Printf("\n=== Tools ===\n%s\n", strings.Join(names, ", "))
+	if req.ToolChoice != nil {
+		choice, _ := json.Marshal(req.ToolChoice)
+		fmt.Printf("tool_choice: %s\n", choice)
+	}
+}
@@ -0,0 +1,500 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// maxDiffCells caps the size of the line comparison table used to render a
+// diff; larger changed regions are shown as a whole-block replacement
+const maxDiffCells = 4 << 20
+
+// diffHunk is one @@ section of a unified diff
+type diffHunk struct {
+	oldStart int      // 1-based line number from the @@ header
+	lines    []string // hunk body, each line prefixed with ' ', '-' or '+'
+}
+
+// hunkCounter tracks how many old and new lines the @@ header of the open
+// hunk promises, so a removed "-- comment" or added "++ x" line inside it is
+// not mistaken for a --- or +++ file header
+type hunkCounter struct {
+	old, new int
+}
+
+// body reports whether line belongs to the hunk by its counts, and counts it
+func (c *hunkCounter) body(line string) bool {
+	if c.old <= 0 && c.new <= 0 {
+		return false
+	}
+	switch {
+	case line == "" || line[0] == ' ':
+		c.old--
+		c.new--
+	case line[0] == '-':
+		c.old--
+	case line[0] == '+':
+		c.new--
+	default:
+		return false
+	}
+	return true
+}
+
+// diffOp is a single line of a computed diff
+type diffOp struct {
+	kind byte // ' ' unchanged, '-' removed, '+' added
+	text string
+}
+
+func (tm *TaskManager) executeEditFiles(ctx context.Context, arguments string) TaskResponse {
+	var params struct {
+		Path string `json:"path"`
+		Diff string `json:"diff"`
+	}
+
+	if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to parse edit_files arguments: %v", err),
+		}
+	}
+
+	tm.progressf("📝 Editing file: %s\n", params.Path)
+
+	if params.Path == "" || params.Diff == "" {
+		return TaskResponse{
+			Status:  "error",
+			Message: "Both path and diff are required",
+		}
+	}
+
+	path, err := tm.resolveToolPath(params.Path)
+	if err != nil {
+		return TaskResponse{
+			Status:  "denied",
+			Message: fmt.Sprintf("Path was denied: %v", err),
+		}
+	}
+
+	perm := fs.FileMode(0644)
+	exists := true
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		exists = false
+	} else if err != nil {
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to read %s: %v", path, err),
+		}
+	} else if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	// The diff is applied to LF, UTF-8 text and written back with the file's
+	// own line endings and encoding
+	format, err := detectTextFormat(data)
+	if err != nil {
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Cannot edit %s: %v", path, err),
+		}
+	}
+	current := format.decode(data)
+
+	updated, err := applyDiff(current, params.Diff)
+	if err != nil {
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to apply diff to %s: %v", path, err),
+		}
+	}
+	encoded, err := format.encode(updated)
+	if err != nil {
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to apply diff to %s: %v", path, err),
+		}
+	}
+	if exists && bytes.Equal(encoded, data) {
+		return TaskResponse{
+			Status:  "success",
+			Message: fmt.Sprintf("Diff leaves %s unchanged", path),
+		}
+	}
+
+	// Show what will actually change, derived from the applied result rather
+	// than the diff the model sent
+	oldName := "a" + path
+	if !exists {
+		oldName = "/dev/null"
+	}
+	preview := unifiedDiff(oldName, "b"+path, current, updated)
+
+	// With --yes the diff is only shown as the tool output
+	if !tm.options.Yes {
+		fmt.Printf("📝 Changes to %s:\n%s", path, colorDiff(preview))
+		if !confirm(ctx, fmt.Sprintf("Apply these changes to %s?", path)) {
+			return TaskResponse{
+				Status:  "denied",
+				Message: fmt.Sprintf("Edit to %s was not confirmed", path),
+				Output:  preview,
+			}
+		}
+	}
+
+	if err := os.WriteFile(path, encoded, perm); err != nil {
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to write %s: %v", path, err),
+			Output:  preview,
+		}
+	}
+
+	return TaskResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Applied diff to %s (%s)", path, format),
+		Output:  preview,
+		script:  editScript(path, preview, encoded, !exists, format),
+	}
+}
+
+// applyUnifiedDiff applies the hunks of a unified diff to content. Each hunk
+// is placed where its context and removed lines match, starting from the line
+// number in its header, so diffs with slightly wrong line numbers still apply.
+func applyUnifiedDiff(content, diff string) (string, error) {
+	hunks, err := parseUnifiedDiff(diff)
+	if err != nil {
+		return "", err
+	}
+
+	lines, trailingNewline := splitLines(content)
+	if content == "" {
+		trailingNewline = true
+	}
+
+	var out []string
+	cursor := 0
+	for i, h := range hunks {
+		var oldBlock, newBlock []string
+		for _, line := range h.lines {
+			switch line[0] {
+			case ' ':
+				oldBlock = append(oldBlock, line[1:])
+				newBlock = append(newBlock, line[1:])
+			case '-':
+				oldBlock = append(oldBlock, line[1:])
+			case '+':
+				newBlock = append(newBlock, line[1:])
+			}
+		}
+
+		pos := findBlock(lines, oldBlock, cursor, h.oldStart-1)
+		if pos < 0 {
+			return "", fmt.Errorf("hunk %d (@@ -%d) does not match the file", i+1, h.oldStart)
+		}
+		out = append(out, lines[cursor:pos]...)
+		out = append(out, newBlock...)
+		cursor = pos + len(oldBlock)
+	}
+	out = append(out, lines[cursor:]...)
+
+	result := strings.Join(out, "\n")
+	if trailingNewline && len(out) > 0 {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// parseUnifiedDiff extracts the hunks from a unified diff, skipping file
+// headers such as diff, index, --- and +++ lines. A --- or +++ line is a
+// header only once the open hunk has all the lines its @@ header counts.
+func parseUnifiedDiff(diff string) ([]diffHunk, error) {
+	var hunks []diffHunk
+	var current *diffHunk
+	var counts hunkCounter
+
+	for _, line := range strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(line, "@@") {
+			oldStart, oldLines, newLines, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			hunks = append(hunks, diffHunk{oldStart: oldStart})
+			current = &hunks[len(hunks)-1]
+			counts = hunkCounter{old: oldLines, new: newLines}
+			continue
+		}
+		if current == nil || strings.HasPrefix(line, "\\") {
+			continue
+		}
+		if !counts.body(line) && (strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "diff ")) {
+			current = nil
+			continue
+		}
+		switch {
+		case line == "":
+			// Models often drop the leading space of empty context lines
+			current.lines = append(current.lines, " ")
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			current.lines = append(current.lines, line)
+		default:
+			return nil, fmt.Errorf("unexpected line in hunk: %q", line)
+		}
+	}
+
+	// A trailing empty line is the end of the diff text, not context
+	for i := range hunks {
+		h := &hunks[i]
+		for len(h.lines) > 0 && h.lines[len(h.lines)-1] == " " {
+			h.lines = h.lines[:len(h.lines)-1]
+		}
+	}
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("no @@ hunks found; the diff must be in unified format")
+	}
+	return hunks, nil
+}
+
+// parseHunkHeader returns the old start line and the old and new line counts
+// of an "@@ -l,s +l,s @@" header. A count left out is 1; one that cannot be
+// read is 0, which leaves the hunk to end at the next header line.
+func parseHunkHeader(line string) (start, oldLines, newLines int, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") {
+		return 0, 0, 0, fmt.Errorf("invalid hunk header: %q", line)
+	}
+	first, oldCount, _ := strings.Cut(strings.TrimPrefix(fields[1], "-"), ",")
+	start, err = strconv.Atoi(first)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid hunk header: %q", line)
+	}
+	oldLines = hunkCount(oldCount)
+	if rest, ok := strings.CutPrefix(fields[2], "+"); ok {
+		_, newCount, _ := strings.Cut(rest, ",")
+		newLines = hunkCount(newCount)
+	}
+	return start, oldLines, newLines, nil
+}
+
+// hunkCount reads the line count of a hunk range, "" meaning 1
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// findBlock returns the index at or after from where block occurs in lines,
+// preferring the match closest to hint. Lines are compared exactly first,
+// then ignoring trailing whitespace. It returns -1 when there is no match.
+func findBlock(lines, block []string, from, hint int) int {
+	if len(block) == 0 {
+		return max(from, min(hint, len(lines)))
+	}
+	for _, equal := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	} {
+		best := -1
+		for pos := from; pos+len(block) <= len(lines); pos++ {
+			if !blockMatches(lines[pos:pos+len(block)], block, equal) {
+				continue
+			}
+			if best < 0 || abs(pos-hint) < abs(best-hint) {
+				best = pos
+			}
+		}
+		if best >= 0 {
+			return best
+		}
+	}
+	return -1
+}
+
+func blockMatches(lines, block []string, equal func(a, b string) bool) bool {
+	for i := range block {
+		if !equal(lines[i], block[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// splitLines splits content into lines and reports whether it ended with a
+// newline
+func splitLines(content string) ([]string, bool) {
+	if content == "" {
+		return nil, false
+	}
+	trailing := strings.HasSuffix(content, "\n")
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n"), trailing
+}
+
+// unifiedDiff renders the difference between two texts as a unified diff
+func unifiedDiff(oldName, newName, oldText, newText string) string {
+	a, _ := splitLines(oldText)
+	b, _ := splitLines(newText)
+	ops := diffLines(a, b)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while the next change is close enough to share context
+		start := max(0, i-diffContext)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			} else if j-end > 2*diffContext {
+				break
+			}
+		}
+		end = min(len(ops), end+1+diffContext)
+
+		oldLine, newLine := 1, 1
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		if oldCount == 0 {
+			oldLine--
+		}
+		if newCount == 0 {
+			newLine--
+		}
+
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+		for _, op := range ops[start:end] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.text)
+			sb.WriteByte('\n')
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// diffLines computes a line diff using the longest common subsequence of the
+// region between the common prefix and suffix
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	am, bm := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(am)*len(bm) > maxDiffCells {
+		for _, line := range am {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range bm {
+			ops = append(ops, diffOp{'+', line})
+		}
+	} else {
+		// lcs[i][j] is the LCS length of am[i:] and bm[j:]
+		lcs := make([][]int, len(am)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(bm)+1)
+		}
+		for i := len(am) - 1; i >= 0; i-- {
+			for j := len(bm) - 1; j >= 0; j-- {
+				if am[i] == bm[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(am) || j < len(bm) {
+			switch {
+			case i < len(am) && j < len(bm) && am[i] == bm[j]:
+				ops = append(ops, diffOp{' ', am[i]})
+				i++
+				j++
+			case i < len(am) && (j == len(bm) || lcs[i+1][j] >= lcs[i][j+1]):
+				ops = append(ops, diffOp{'-', am[i]})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', bm[j]})
+				j++
+			}
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// colorDiff colors added lines green and removed lines red for display
+func colorDiff(diff string) string {
+	if !colorEnabled {
+		return diff
+	}
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			sb.WriteString(line)
+		case strings.HasPrefix(line, "+"):
+			sb.WriteString(colorize(ansiGreen, strings.TrimSuffix(line, "\n")) + "\n")
+		case strings.HasPrefix(line, "-"):
+			sb.WriteString(colorize(ansiRed, strings.TrimSuffix(line, "\n")) + "\n")
+		default:
+			sb.WriteString(line)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,39 @@
+package cli
+
+import "testing"
+
+func TestApplyDiffKeepsLinesThatLookLikeHeaders(t *testing.T) {
+	content := "SELECT 1;\n-- old comment\nSELECT 2;\n"
+	diff := "--- a/q.sql\n+++ b/q.sql\n@@ -1,3 +1,3 @@\n SELECT 1;\n--- old comment\n+++ new comment\n SELECT 2;\n"
+	got, err := applyDiff(content, diff)
+	if err != nil {
+		t.Fatalf("applyDiff: %v", err)
+	}
+	if want := "SELECT 1;\n++ new comment\nSELECT 2;\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseUnifiedDiffEndsHunkAtHeaderAfterCounts(t *testing.T) {
+	diff := "@@ -1,2 +1,2 @@\n a\n-b\n+B\n--- a/f\n+++ b/f\n@@ -5 +5 @@\n-e\n+E\n"
+	hunks, err := parseUnifiedDiff(diff)
+	if err != nil {
+		t.Fatalf("parseUnifiedDiff: %v", err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(hunks))
+	}
+	if got := len(hunks[0].lines); got != 3 {
+		t.Errorf("first hunk has %d lines, want 3: %q", got, hunks[0].lines)
+	}
+	if hunks[1].oldStart != 5 || len(hunks[1].lines) != 2 {
+		t.Errorf("second hunk = %+v", hunks[1])
+	}
+}
+
+func TestCountDiffFilesIgnoresHunkLines(t *testing.T) {
+	diff := "--- a/q.sql\n+++ b/q.sql\n@@ -1,2 +1,2 @@\n--- old\n+++ new\n x\n"
+	if got := countDiffFiles(diff); got != 1 {
+		t.Errorf("countDiffFiles = %d, want 1", got)
+	}
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// reviewToolCall shows a tool call before it runs (--edit) and lets the user
+// run it as proposed, correct it or skip it. It returns the arguments to run
+// with, which are the proposed ones unless the user changed something, and
+// an error when the call is skipped. Without a terminal it does nothing.
+func (tm *TaskManager) reviewToolCall(ctx context.Context, tool, arguments string) (string, error) {
+	if !tm.options.EditToolCalls || !isTerminal(os.Stdin) {
+		return arguments, nil
+	}
+	for {
+		text := editableText(tool, arguments)
+		fmt.Printf("✏️  %s:\n%s\n", tool, indent(text, "   "))
+		fmt.Print("❓ Run it? [Y]es, [e]dit, [n]o: ")
+		answer, ok := readLine(ctx)
+		if !ok {
+			fmt.Println()
+			return "", fmt.Errorf("%s was not run", tool)
+		}
+
+		switch strings.ToLower(answer) {
+		case "", "y", "yes":
+			return arguments, nil
+		case "n", "no":
+			return "", fmt.Errorf("%s was skipped by the user", tool)
+		case "e", "edit":
+			edited, err := editText(ctx, text, editableCommand(tool, arguments) != "")
+			if err != nil {
+				printWarning("⚠️  %v\n", err)
+				continue
+			}
+			if edited == "" || edited == text {
+				continue
+			}
+			updated, err := applyEditedText(tool, arguments, edited)
+			if err != nil {
+				printWarning("⚠️  %v\n", err)
+				continue
+			}
+			arguments = updated
+		}
+	}
+}
+
+// editableText is what the user edits: the command line for run_commands,
+// the pretty-printed arguments for other tools
+func editableText(tool, arguments string) string {
+	if command := editableCommand(tool, arguments); command != "" {
+		return command
+	}
+	return prettyArguments(arguments)
+}
+
+// editableCommand returns the command of a run_commands call, or "" when the
+// arguments are edited as JSON
+func editableCommand(tool, arguments string) string {
+	var params struct {
+		Command string `json:"command"`
+	}
+	if tool != "run_commands" || json.Unmarshal([]byte(arguments), &params) != nil {
+		return ""
+	}
+	return params.Command
+}
+
+// applyEditedText turns edited text back into arguments, keeping the other
+// run_commands parameters when only the command was edited
+func applyEditedText(tool, arguments, edited string) (string, error) {
+	if editableCommand(tool, arguments) != "" {
+		params := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+			return "", fmt.Errorf("cannot edit %s arguments: %v", tool, err)
+		}
+		params["command"] = edited
+		data, err := json.Marshal(params)
+		return string(data), err
+	}
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(edited), &params); err != nil {
+		return "", fmt.Errorf("edited arguments are not a JSON object: %v", err)
+	}
+	data, err := json.Marshal(params)
+	return string(data), err
+}
+
+// editText lets the user change text, a command line or JSON arguments, in
+// $VISUAL or $EDITOR, or inline on a single line when neither is set, and
+// returns the result trimmed
+func editText(ctx context.Context, text string, isCommand bool) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		if isCommand {
+			fmt.Print("✏️  New command (empty keeps it): ")
+		} else {
+			fmt.Print("✏️  New arguments as one line of JSON (empty keeps them): ")
+		}
+		line, ok := readLine(ctx)
+		if !ok {
+			return "", errors.New("no input")
+		}
+		return line, nil
+	}
+
+	suffix := ".json"
+	if isCommand {
+		suffix = ".sh"
+	}
+	file, err := os.CreateTemp("", "tinypenguin-*"+suffix)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(text + "\n"); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+
+	// Run through the shell so EDITOR may carry arguments, e.g. "code --wait"
+	cmd := exec.CommandContext(ctx, "sh", "-c", editor+` "$1"`, "sh", file.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %q failed: %v", editor, err)
+	}
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
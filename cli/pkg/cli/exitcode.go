@@ -0,0 +1,42 @@
+package cli
+
+import "errors"
+
+// Process exit codes reported by tinypenguin-cli
+const (
+	ExitSuccess    = 0   // Every executed tool succeeded, or the model answered without tools
+	ExitFailure    = 1   // Infrastructure failure: bad flags, unreachable endpoint, log errors
+	ExitToolFailed = 2   // At least one tool call returned error or was denied
+	ExitNoAction   = 3   // The model returned no actionable response
+	ExitTimeout    = 124 // The --task-timeout budget ran out
+	ExitCancelled  = 130 // Interrupted by Ctrl-C or SIGTERM
+)
+
+var (
+	// ErrToolFailed is returned when any executed tool call failed or was denied
+	ErrToolFailed = errors.New("one or more tool calls failed or were denied")
+	// ErrNoAction is returned when the model response contained nothing to run or show
+	ErrNoAction = errors.New("model returned no actionable response")
+	// ErrCancelled is returned when the task was interrupted
+	ErrCancelled = errors.New("task cancelled")
+	// ErrTaskTimeout is returned when the task ran past its --task-timeout budget
+	ErrTaskTimeout = errors.New("task budget exceeded")
+)
+
+// ExitCode maps an error returned by RunTask to a process exit code
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.Is(err, ErrToolFailed):
+		return ExitToolFailed
+	case errors.Is(err, ErrNoAction):
+		return ExitNoAction
+	case errors.Is(err, ErrTaskTimeout):
+		return ExitTimeout
+	case errors.Is(err, ErrCancelled):
+		return ExitCancelled
+	default:
+		return ExitFailure
+	}
+}
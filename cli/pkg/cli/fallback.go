@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// chat sends a chat request, trying the configured fallback models in order
+// when the requested model is not available. It returns the response and the
+// model that actually served it.
+func (tm *TaskManager) chat(ctx context.Context, req *common.ChatRequest) (*common.ChatResponse, string, error) {
+	models := []string{req.Model}
+	for _, m := range tm.options.ModelFallback {
+		if m != "" && m != req.Model {
+			models = append(models, m)
+		}
+	}
+
+	messages := trimMessages(req.Messages, tm.options.ContextTokens)
+
+	var lastErr error
+	for i, model := range models {
+		attempt := *req
+		attempt.Messages = messages
+		attempt.Model = model
+		attempt.KeepAlive = tm.options.KeepAlive
+		resp, err := tm.tinyllamaClient.Chat(ctx, &attempt)
+		if err == nil {
+			recordUsage(ctx, resp.Usage)
+			if i > 0 {
+				tm.progressf("↪️  Model %s unavailable, request served by %s\n", req.Model, model)
+				slog.Warn("model fallback used", "requested", req.Model, "served_by", model)
+			}
+			return resp, model, nil
+		}
+		if len(models) == 1 || !errors.Is(err, common.ErrModelNotFound) {
+			return nil, model, err
+		}
+		slog.Warn("model not available", "model", model, "error", err)
+		lastErr = err
+	}
+	return nil, req.Model, fmt.Errorf("no available model among %s: %w", strings.Join(models, ", "), lastErr)
+}
+
+// chatErrorHint suggests a fix for the API failures users can act on, or
+// returns "" for other errors
+func chatErrorHint(err error, model string) string {
+	switch {
+	case errors.Is(err, common.ErrModelNotFound):
+		return fmt.Sprintf("pull the model first, e.g. `ollama pull %s`, or pass --model-fallback", model)
+	case errors.Is(err, common.ErrUnauthorized):
+		return "the server rejected the request; check its access settings"
+	case errors.Is(err, common.ErrRateLimited):
+		return "the server is rate limiting requests; try again later"
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// maxFetchBodyBytes caps how much of a response body http_fetch reads
+const maxFetchBodyBytes = 64 * 1024
+
+// httpFetchTool is the definition of the http_fetch tool, offered only with
+// --allow-network
+func httpFetchTool() common.Tool {
+	return common.CreateToolDefinition(
+		"http_fetch",
+		"Fetch a URL over HTTP(S) and return the status code and response body",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "http:// or https:// URL to fetch",
+				},
+				"method": map[string]interface{}{
+					"type":        "string",
+					"description": "HTTP method (default GET)",
+					"enum":        []interface{}{"GET", "HEAD", "POST", "PUT", "DELETE"},
+				},
+				"headers": map[string]interface{}{
+					"type":        "object",
+					"description": "Request headers (optional)",
+				},
+				"timeout": map[string]interface{}{
+					"type":        "integer",
+					"description": "Timeout in seconds (optional)",
+				},
+			},
+			"required": []interface{}{"url"},
+		},
+	)
+}
+
+func (tm *TaskManager) executeHTTPFetch(parent context.Context, arguments string) (result TaskResponse) {
+	defer func() { result = tm.capOutput(result) }()
+
+	var params struct {
+		URL     string            `json:"url"`
+		Method  string            `json:"method,omitempty"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Timeout *int              `json:"timeout,omitempty"`
+	}
+
+	if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to parse http_fetch arguments: %v", err),
+		}
+	}
+	if params.Method == "" {
+		params.Method = "GET"
+	}
+
+	tm.progressf("🌐 Fetching: %s %s\n", params.Method, params.URL)
+
+	target, err := url.Parse(params.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Invalid URL %q: only http and https URLs are supported", params.URL),
+		}
+	}
+
+	timeout := 30 * time.Second
+	if params.Timeout != nil {
+		timeout = time.Duration(*params.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, params.Method, target.String(), nil)
+	if err != nil {
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to create request: %v", err),
+		}
+	}
+	for name, value := range params.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	tm.audit(params.Method+" "+target.String(), tm.workdir(), tm.commandDecision(), nil, fetchStatus(resp, err))
+	if err != nil {
+		if parent.Err() != nil {
+			return TaskResponse{
+				Status:  "cancelled",
+				Message: "Request was cancelled",
+			}
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return TaskResponse{
+				Status:  "error",
+				Message: "Request timed out",
+			}
+		}
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Request failed: %v", err),
+		}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBodyBytes+1))
+	if err != nil {
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to read response body: %v", err),
+		}
+	}
+	output := string(body)
+	if len(body) > maxFetchBodyBytes {
+		output = truncateUTF8(output, maxFetchBodyBytes) + fmt.Sprintf("\n...body truncated at %d bytes", maxFetchBodyBytes)
+	}
+
+	var headers strings.Builder
+	fmt.Fprintf(&headers, "HTTP %s\n", resp.Status)
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		fmt.Fprintf(&headers, "Content-Type: %s\n", contentType)
+	}
+
+	if resp.StatusCode >= 400 {
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Request returned %s", resp.Status),
+			Output:  headers.String() + "\n" + output,
+		}
+	}
+	return TaskResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Fetched %s (%s)", target.String(), resp.Status),
+		Output:  headers.String() + "\n" + output,
+	}
+}
+
+// fetchStatus summarises a request outcome for the audit log
+func fetchStatus(resp *http.Response, err error) string {
+	if err != nil || resp.StatusCode >= 400 {
+		return "error"
+	}
+	return "success"
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// RunGenerate sends a raw prompt to the generate endpoint, without the chat
+// system prompt or tools, and prints the completion
+func RunGenerate(prompt string, tinyllamaURL string, model string, debugMode bool, options TaskOptions, stream bool) error {
+	manager, err := NewTaskManagerWithDefaults(tinyllamaURL, model, false, debugMode, options)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	req := &common.GenerateRequest{
+		Model:  manager.model,
+		Prompt: prompt,
+		Stream: stream,
+
+		KeepAlive: options.KeepAlive,
+	}
+	if debugMode {
+		fmt.Printf("🐛 DEBUG - keep_alive: %s\n", manager.keepAliveSummary(true))
+	}
+
+	var onChunk func(*common.GenerateResponse)
+	if stream {
+		onChunk = func(chunk *common.GenerateResponse) {
+			fmt.Print(chunk.Response)
+		}
+	}
+
+	resp, err := manager.tinyllamaClient.Generate(ctx, req, onChunk)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ErrCancelled
+		}
+		return fmt.Errorf("generate request failed: %w", err)
+	}
+
+	if stream {
+		fmt.Println()
+	} else {
+		fmt.Println(resp.Response)
+	}
+
+	if debugMode {
+		fmt.Printf("🐛 DEBUG - Model: %s\n", resp.Model)
+		fmt.Printf("🐛 DEBUG - Prompt tokens: %d in %v\n", resp.PromptEvalCount, time.Duration(resp.PromptEvalDuration))
+		fmt.Printf("🐛 DEBUG - Completion tokens: %d in %v", resp.EvalCount, time.Duration(resp.EvalDuration))
+		if resp.EvalDuration > 0 {
+			fmt.Printf(" (%.1f tokens/s)", float64(resp.EvalCount)/time.Duration(resp.EvalDuration).Seconds())
+		}
+		fmt.Println()
+		fmt.Printf("🐛 DEBUG - Load time: %v, total: %v\n", time.Duration(resp.LoadDuration), time.Duration(resp.TotalDuration))
+	}
+	return nil
+}
+
+// keepAliveSummary describes the effective keep_alive for --debug; native
+// says whether the request goes to a native endpoint, the only kind that
+// takes it
+func (tm *TaskManager) keepAliveSummary(native bool) string {
+	switch {
+	case tm.options.KeepAlive == "":
+		return "not set, the server default applies (OLLAMA_KEEP_ALIVE, 5m unless configured)"
+	case !native:
+		return fmt.Sprintf("%s not sent: the OpenAI-compatible API has no keep_alive, use --api-style ollama", tm.options.KeepAlive)
+	case strings.HasPrefix(string(tm.options.KeepAlive), "-"):
+		return fmt.Sprintf("%s, the model stays loaded until the server stops", tm.options.KeepAlive)
+	}
+	return string(tm.options.KeepAlive)
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// logEntryHash identifies a log entry by its content, so the same entry in
+// a copied or rotated log matches
+func logEntryHash(entry ToolCallLog) [sha256.Size]byte {
+	data, _ := json.Marshal(entry)
+	return sha256.Sum256(data)
+}
+
+// DiffLogs reports the entries of newPath that are not in oldPath, by
+// content, summarized by tool and rating next to the old log's ratings so a
+// drop in quality between sessions or model versions stands out
+func DiffLogs(oldPath, newPath string) error {
+	oldLogs, err := readToolCallLogs(oldPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", oldPath, err)
+	}
+	newLogs, err := readToolCallLogs(newPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", newPath, err)
+	}
+
+	inOld := make(map[[sha256.Size]byte]bool, len(oldLogs))
+	for _, entry := range oldLogs {
+		inOld[logEntryHash(entry)] = true
+	}
+	var added []ToolCallLog
+	inNew := make(map[[sha256.Size]byte]bool, len(newLogs))
+	for _, entry := range newLogs {
+		hash := logEntryHash(entry)
+		if !inOld[hash] && !inNew[hash] {
+			added = append(added, entry)
+		}
+		inNew[hash] = true
+	}
+	removed := 0
+	for hash := range inOld {
+		if !inNew[hash] {
+			removed++
+		}
+	}
+
+	fmt.Printf("📊 %s: %d entries, %s: %d entries\n", oldPath, len(oldLogs), newPath, len(newLogs))
+	fmt.Printf("➕ %d new, ➖ %d no longer present\n", len(added), removed)
+	if len(added) == 0 {
+		return nil
+	}
+
+	byTool := make(map[string][]ToolCallLog)
+	byRating := make(map[string]int)
+	for _, entry := range added {
+		byTool[entry.ToolName] = append(byTool[entry.ToolName], entry)
+		if entry.Rating > 0 {
+			byRating[strconv.Itoa(entry.Rating)+"★"]++
+		} else {
+			byRating["unrated"]++
+		}
+	}
+
+	tools := make([]string, 0, len(byTool))
+	for tool := range byTool {
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		if len(byTool[tools[i]]) != len(byTool[tools[j]]) {
+			return len(byTool[tools[i]]) > len(byTool[tools[j]])
+		}
+		return tools[i] < tools[j]
+	})
+	fmt.Printf("\n%-20s  %5s  %7s  %s\n", "TOOL", "NEW", "SUCCESS", "AVG RATING")
+	for _, tool := range tools {
+		entries := byTool[tool]
+		succeeded := 0
+		for _, entry := range entries {
+			if entry.Status == "success" {
+				succeeded++
+			}
+		}
+		fmt.Printf("%-20s  %5d  %6.0f%%  %s\n", tool, len(entries), 100*float64(succeeded)/float64(len(entries)), averageRating(entries))
+	}
+
+	fmt.Printf("\n%-20s  %5s\n", "RATING", "NEW")
+	for _, rating := range []string{"5★", "4★", "3★", "2★", "1★", "unrated"} {
+		if n := byRating[rating]; n > 0 {
+			fmt.Printf("%-20s  %5d\n", rating, n)
+		}
+	}
+	fmt.Printf("\n⭐ Average rating: %s in %s, %s in the new entries\n", averageRating(oldLogs), oldPath, averageRating(added))
+	return nil
+}
+
+// averageRating formats the mean rating of the rated entries and how many
+// were rated
+func averageRating(entries []ToolCallLog) string {
+	sum, rated := 0, 0
+	for _, entry := range entries {
+		if entry.Rating > 0 {
+			sum += entry.Rating
+			rated++
+		}
+	}
+	if rated == 0 {
+		return "- (none rated)"
+	}
+	return fmt.Sprintf("%.1f (%d rated)", float64(sum)/float64(rated), rated)
+}
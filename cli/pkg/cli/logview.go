@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// logFollowInterval is how often --follow checks the log for new entries
+const logFollowInterval = time.Second
+
+// LogViewOptions selects and formats the entries shown by ShowLog
+type LogViewOptions struct {
+	Limit  int       // Number of most recent entries to show; 0 means all
+	Tool   string    // Only show entries for this tool
+	Status string    // Only show entries with this status
+	Since  time.Time // Only show entries logged at or after this time
+	JSON   bool      // Print entries as JSON lines instead of a table
+	Follow bool      // Keep printing new entries as they are appended
+	Task   string    // Show the output of this detached task instead of the tool call log
+}
+
+// matches reports whether an entry passes the filters
+func (o LogViewOptions) matches(entry ToolCallLog) bool {
+	return (o.Tool == "" || entry.ToolName == o.Tool) &&
+		(o.Status == "" || entry.Status == o.Status) &&
+		(o.Since.IsZero() || !entry.Timestamp.Before(o.Since))
+}
+
+// ShowLog prints recent tool_calls.log entries as a table or as JSON, and
+// with Follow keeps printing new entries until interrupted
+func ShowLog(opts LogViewOptions) error {
+	if opts.Task != "" {
+		return showTaskOutput(opts.Task, opts.Follow)
+	}
+	logPath := getLogPath()
+	logs, err := readToolCallLogs(logPath)
+	if err != nil && !(errors.Is(err, os.ErrNotExist) && opts.Follow) {
+		return fmt.Errorf("failed to read %s: %w", logPath, err)
+	}
+
+	// Entries are numbered by their position in the whole log, as used by replay
+	var selected []int
+	for i, entry := range logs {
+		if opts.matches(entry) {
+			selected = append(selected, i)
+		}
+	}
+	if opts.Limit > 0 && len(selected) > opts.Limit {
+		selected = selected[len(selected)-opts.Limit:]
+	}
+
+	if !opts.JSON {
+		printLogHeader()
+	}
+	for _, i := range selected {
+		printLogEntry(i+1, logs[i], opts.JSON)
+	}
+	if !opts.Follow {
+		return nil
+	}
+	return followLog(logPath, len(logs), opts)
+}
+
+// followLog polls the log and prints entries appended after the current end.
+// count is the number of entries already in the log.
+func followLog(logPath string, count int, opts LogViewOptions) error {
+	ctx, stop := signalContext()
+	defer stop()
+
+	var offset int64
+	if info, err := os.Stat(logPath); err == nil {
+		offset = info.Size()
+	}
+
+	ticker := time.NewTicker(logFollowInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(logPath)
+		if err != nil {
+			continue
+		}
+		// The log was rewritten (review, rotation); continue from its new end
+		if info.Size() < offset {
+			offset = info.Size()
+			logs, _ := readToolCallLogs(logPath)
+			count = len(logs)
+			continue
+		}
+		if info.Size() == offset {
+			continue
+		}
+
+		file, err := os.Open(logPath)
+		if err != nil {
+			continue
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			continue
+		}
+		reader := bufio.NewReader(file)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				break // Partial lines are picked up on the next poll
+			}
+			offset += int64(len(line))
+			var entry ToolCallLog
+			if json.Unmarshal([]byte(line), &entry) != nil {
+				continue
+			}
+			count++
+			if opts.matches(entry) {
+				printLogEntry(count, entry, opts.JSON)
+			}
+		}
+		file.Close()
+	}
+}
+
+func printLogHeader() {
+	fmt.Printf("%5s  %-19s  %-14s  %-9s  %-6s  %s\n", "#", "TIME", "TOOL", "STATUS", "RATING", "ARGUMENTS")
+}
+
+// printLogEntry prints one entry as a table row, numbered with its position
+// in the log, or as a JSON line
+func printLogEntry(index int, entry ToolCallLog, asJSON bool) {
+	if asJSON {
+		data, _ := json.Marshal(entry)
+		fmt.Println(string(data))
+		return
+	}
+
+	rating := "-"
+	if entry.Rating > 0 {
+		rating = fmt.Sprintf("%d/5", entry.Rating)
+	}
+	status := fmt.Sprintf("%-9s", entry.Status)
+	fmt.Printf("%5d  %-19s  %-14s  %s  %-6s  %s\n", index,
+		entry.Timestamp.Local().Format("2006-01-02 15:04:05"), entry.ToolName,
+		colorize(statusColor(entry.Status), status), rating, summarizeArguments(entry.Arguments, 60))
+}
+
+// summarizeArguments shows the command for run_commands-style arguments and
+// compact JSON otherwise, cut to at most n characters on one line
+func summarizeArguments(arguments string, n int) string {
+	summary := arguments
+	var args map[string]interface{}
+	if json.Unmarshal([]byte(arguments), &args) == nil {
+		if command, ok := args["command"].(string); ok {
+			summary = command
+		}
+	}
+	summary = strings.Join(strings.Fields(summary), " ")
+	if len([]rune(summary)) > n {
+		summary = string([]rune(summary)[:n-3]) + "..."
+	}
+	return summary
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// osInfo describes the host distribution
+type osInfo struct {
+	ID             string   // os-release ID, e.g. fedora, ubuntu
+	IDLike         []string // os-release ID_LIKE, e.g. [rhel fedora]
+	Name           string   // PRETTY_NAME or NAME
+	Version        string   // VERSION_ID
+	PackageManager string   // dnf, yum, apt, zypper, pacman or apk; empty if unknown
+	Kernel         string   // uname -srm, e.g. Linux 6.8.0 x86_64
+}
+
+// hostOS is the detected host, computed once per process
+var hostOS = sync.OnceValue(detectOS)
+
+// detected reports whether the distribution could be identified
+func (o osInfo) detected() bool {
+	return o.ID != ""
+}
+
+// redHatFamily reports whether the host is RHEL, Fedora or a derivative
+func (o osInfo) redHatFamily() bool {
+	ids := append([]string{o.ID}, o.IDLike...)
+	return slices.Contains(ids, "rhel") || slices.Contains(ids, "fedora")
+}
+
+// String describes the host for the system prompt
+func (o osInfo) String() string {
+	var parts []string
+	if o.Name != "" {
+		parts = append(parts, o.Name)
+	} else if o.ID != "" {
+		parts = append(parts, strings.TrimSpace(o.ID+" "+o.Version))
+	}
+	if o.Kernel != "" {
+		parts = append(parts, fmt.Sprintf("kernel %s", o.Kernel))
+	}
+	if o.PackageManager != "" {
+		parts = append(parts, fmt.Sprintf("package manager %s", o.PackageManager))
+	}
+	if len(parts) == 0 {
+		return "unknown"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// readOSRelease parses an os-release file into key/value pairs
+func readOSRelease(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		fields[key] = strings.Trim(value, `"'`)
+	}
+	return fields, scanner.Err()
+}
+
+// detectOS reads /etc/os-release and uname and works out the package
+// manager. Fields that cannot be determined are left empty.
+func detectOS() osInfo {
+	var info osInfo
+	fields, err := readOSRelease("/etc/os-release")
+	if err == nil {
+		info.ID = fields["ID"]
+		info.IDLike = strings.Fields(fields["ID_LIKE"])
+		info.Name = fields["PRETTY_NAME"]
+		if info.Name == "" {
+			info.Name = fields["NAME"]
+		}
+		info.Version = fields["VERSION_ID"]
+	}
+	info.PackageManager = detectPackageManager(append([]string{info.ID}, info.IDLike...))
+	if out, err := exec.Command("uname", "-srm").Output(); err == nil {
+		info.Kernel = strings.TrimSpace(string(out))
+	}
+	return info
+}
+
+// personaPrompt opens the system prompt. Red Hat hosts, and hosts that
+// cannot be identified, get the RHCSA persona; others get a persona for their
+// distribution and package manager.
+func personaPrompt(o osInfo) string {
+	if !o.detected() || o.redHatFamily() {
+		return `You are a Red Hat Certified System Administrator (RHCSA) assistant. 
+You help with Linux system administration tasks including:
+- File system operations (create, edit, delete files)
+- Package management (yum/dnf, rpm)
+- Service management (systemctl)
+- User and group management
+- Network configuration
+- Security (SELinux, firewall, permissions)
+`
+	}
+
+	packages := "the distribution's package manager"
+	if o.PackageManager != "" {
+		packages = o.PackageManager
+	}
+	return fmt.Sprintf(`You are a Linux system administrator assistant for %s. 
+Use commands that work on this distribution; do not suggest yum/dnf on non-Red Hat systems.
+You help with Linux system administration tasks including:
+- File system operations (create, edit, delete files)
+- Package management (%s)
+- Service management (systemctl)
+- User and group management
+- Network configuration
+- Security (firewall, permissions, mandatory access control)
+`, o.Name, packages)
+}
+
+// detectPackageManager picks the package manager for a distro family,
+// falling back to whichever known manager is installed
+func detectPackageManager(ids []string) string {
+	for _, id := range ids {
+		switch id {
+		case "fedora", "rhel", "centos", "rocky", "almalinux", "ol":
+			if _, err := exec.LookPath("dnf"); err == nil {
+				return "dnf"
+			}
+			return "yum"
+		case "debian", "ubuntu":
+			return "apt"
+		case "suse", "opensuse", "sles":
+			return "zypper"
+		case "arch":
+			return "pacman"
+		case "alpine":
+			return "apk"
+		}
+	}
+	for _, pm := range []string{"dnf", "yum", "apt-get", "zypper", "pacman", "apk"} {
+		if _, err := exec.LookPath(pm); err == nil {
+			return strings.TrimSuffix(pm, "-get")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// binaryThreshold is the share of control bytes above which output is
+// treated as binary and suppressed
+const binaryThreshold = 0.1
+
+// With --output-dir, the terminal, log and model see only this much of each
+// tool output
+const (
+	outputHeadLines = 10
+	outputHeadBytes = 1000
+)
+
+// ansiEscape matches terminal color and cursor sequences
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// displayOutput returns the output to show on screen. It is the untruncated
+// output when the model-facing output was capped.
+func (r TaskResponse) displayOutput() string {
+	if r.fullOutput != "" {
+		return r.fullOutput
+	}
+	return r.Output
+}
+
+// capOutput truncates the model-facing output to the configured maximum. The
+// complete output is kept for display and saved to a side file referenced in
+// the truncation notice.
+func (tm *TaskManager) capOutput(result TaskResponse) TaskResponse {
+	limit := tm.options.MaxOutputBytes
+	if limit <= 0 || len(result.Output) <= limit || tm.options.OutputDir != "" {
+		// With --output-dir the full output goes to its own file instead
+		return result
+	}
+
+	full := result.Output
+	dropped := len(full) - limit
+	notice := fmt.Sprintf("\n...truncated %d bytes", dropped)
+	if path, err := saveFullOutput(full); err == nil {
+		notice += fmt.Sprintf(" (full output saved to %s)", path)
+	}
+
+	result.fullOutput = full
+	result.Output = truncateUTF8(full, limit) + notice
+	return result
+}
+
+// writeOutputFile saves the complete output of a tool call to
+// <OutputDir>/<timestamp>-<tool>.txt and replaces the output with its first
+// lines and the path of the file. It does nothing without --output-dir; if
+// the file cannot be written the output is left as it is.
+func (tm *TaskManager) writeOutputFile(tool string, result TaskResponse) TaskResponse {
+	full := result.displayOutput()
+	if tm.options.OutputDir == "" || full == "" {
+		return result
+	}
+
+	path, err := createOutputFile(tm.options.OutputDir, tool, full)
+	if err != nil {
+		tm.warnf("⚠️  Failed to save %s output: %v\n", tool, err)
+		return result
+	}
+
+	head := full
+	if lines := strings.SplitAfter(head, "\n"); len(lines) > outputHeadLines {
+		head = strings.Join(lines[:outputHeadLines], "")
+	}
+	head = strings.TrimRight(truncateUTF8(head, outputHeadBytes), "\n")
+	if len(head) < len(strings.TrimRight(full, "\n")) {
+		head += "\n..."
+	}
+	result.fullOutput = ""
+	result.Output = fmt.Sprintf("%s\n[full output, %d bytes: %s]", head, len(full), path)
+	return result
+}
+
+// createOutputFile writes output to a new file named after the current time
+// and the tool, adding a counter when calls finish within the same instant
+func createOutputFile(dir, tool, output string) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	stamp := time.Now().Format("20060102-150405.000")
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("%s-%s.txt", stamp, tool)
+		if n > 1 {
+			name = fmt.Sprintf("%s-%s-%d.txt", stamp, tool, n)
+		}
+		path := filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.WriteString(output); err != nil {
+			f.Close()
+			return "", err
+		}
+		return path, f.Close()
+	}
+}
+
+// sanitizeOutput makes command output safe to print and to store in the JSON
+// log. Binary output (invalid UTF-8, NUL bytes or mostly control characters)
+// is replaced by a notice, terminal escape sequences are removed and other
+// control characters are replaced. --raw-output disables this.
+func (tm *TaskManager) sanitizeOutput(output []byte) string {
+	if tm.options.RawOutput {
+		return string(output)
+	}
+	output = ansiEscape.ReplaceAll(output, nil)
+	if isBinary(output) {
+		return fmt.Sprintf("[binary output suppressed (%d bytes)]", len(output))
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' || r == '\r' || !unicode.IsControl(r) {
+			return r
+		}
+		return utf8.RuneError
+	}, string(output))
+}
+
+// isBinary guesses whether output is binary rather than text
+func isBinary(output []byte) bool {
+	if len(output) == 0 {
+		return false
+	}
+	if !utf8.Valid(output) {
+		return true
+	}
+	control := 0
+	for _, b := range output {
+		if b == 0 {
+			return true
+		}
+		if b < 0x20 && b != '\n' && b != '\t' && b != '\r' {
+			control++
+		}
+	}
+	return float64(control)/float64(len(output)) > binaryThreshold
+}
+
+// saveFullOutput writes output to a temporary file and returns its path
+func saveFullOutput(output string) (string, error) {
+	f, err := os.CreateTemp("", "tinypenguin-output-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(output); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && n < len(s) && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"time"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// packageTimeout allows for slow mirrors and large transactions
+const packageTimeout = 10 * time.Minute
+
+// validPackageName rejects anything that could be interpreted by the shell
+var validPackageName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+:@-]*$`)
+
+// packageCommands maps each package manager to the command for each action.
+// %s is replaced with the package name.
+var packageCommands = map[string]map[string]string{
+	"dnf":    {"install": "dnf install -y %s", "remove": "dnf remove -y %s", "update": "dnf upgrade -y %s", "update-all": "dnf upgrade -y", "query": "rpm -q %s"},
+	"yum":    {"install": "yum install -y %s", "remove": "yum remove -y %s", "update": "yum update -y %s", "update-all": "yum update -y", "query": "rpm -q %s"},
+	"apt":    {"install": "apt-get install -y %s", "remove": "apt-get remove -y %s", "update": "apt-get install --only-upgrade -y %s", "update-all": "apt-get update && apt-get upgrade -y", "query": "dpkg -s %s"},
+	"zypper": {"install": "zypper --non-interactive install %s", "remove": "zypper --non-interactive remove %s", "update": "zypper --non-interactive update %s", "update-all": "zypper --non-interactive update", "query": "rpm -q %s"},
+	"pacman": {"install": "pacman -S --noconfirm %s", "remove": "pacman -R --noconfirm %s", "update": "pacman -S --noconfirm %s", "update-all": "pacman -Syu --noconfirm", "query": "pacman -Q %s"},
+	"apk":    {"install": "apk add %s", "remove": "apk del %s", "update": "apk upgrade %s", "update-all": "apk upgrade", "query": "apk info -e %s"},
+}
+
+// managePackageTool is the definition of the manage_package tool
+func managePackageTool() common.Tool {
+	return common.CreateToolDefinition(
+		"manage_package",
+		"Install, remove, update or query a package with the system's package manager (detected automatically)",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"action": map[string]interface{}{
+					"type":        "string",
+					"description": "What to do with the package",
+					"enum":        []interface{}{"install", "remove", "update", "query"},
+				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Package name; may be omitted for update to update everything",
+				},
+			},
+			"required": []interface{}{"action"},
+		},
+	)
+}
+
+// packageCommand builds the shell command for an action, using sudo for
+// changes when not running as root
+func packageCommand(manager, action, name string) (string, error) {
+	commands, ok := packageCommands[manager]
+	if !ok {
+		return "", fmt.Errorf("no supported package manager detected")
+	}
+	if name == "" {
+		if action != "update" {
+			return "", fmt.Errorf("name is required for %s", action)
+		}
+		action = "update-all"
+	} else if !validPackageName.MatchString(name) {
+		return "", fmt.Errorf("invalid package name %q", name)
+	}
+
+	command := commands[action]
+	if name != "" {
+		command = fmt.Sprintf(command, name)
+	}
+	if action != "query" && os.Geteuid() != 0 {
+		command = "sudo sh -c '" + command + "'"
+	}
+	return command, nil
+}
+
+func (tm *TaskManager) executeManagePackage(ctx context.Context, arguments string) (result TaskResponse) {
+	defer func() { result = tm.capOutput(result) }()
+
+	var params struct {
+		Action string `json:"action"`
+		Name   string `json:"name,omitempty"`
+	}
+
+	if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to parse manage_package arguments: %v", err),
+		}
+	}
+
+	manager := hostOS().PackageManager
+	command, err := packageCommand(manager, params.Action, params.Name)
+	if err != nil {
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Cannot %s package: %v", params.Action, err),
+		}
+	}
+
+	tm.progressf("📦 Package %s via %s: %s\n", params.Action, manager, command)
+
+	if reason := tm.deniedReason(command); reason != "" {
+		slog.Warn("command denied", "command", command, "reason", reason)
+		tm.audit(command, tm.workdir(), "denied", nil, "denied")
+		return TaskResponse{
+			Status:  "denied",
+			Message: fmt.Sprintf("Package %s was denied: %s", params.Action, reason),
+		}
+	}
+
+	// Installing and removing software changes the system; ask first. Safe
+	// mode asks for queries too.
+	decision := tm.commandDecision()
+	if (params.Action == "install" || params.Action == "remove" || tm.options.Safe) && !tm.options.Plan && !tm.options.Yes {
+		if !confirm(ctx, fmt.Sprintf("Run `%s`?", command)) {
+			tm.audit(command, tm.workdir(), "denied", nil, "denied")
+			return TaskResponse{
+				Status:  "denied",
+				Message: fmt.Sprintf("Package %s was not confirmed", params.Action),
+			}
+		}
+		decision = "confirmed"
+	}
+
+	result = tm.runCommand(ctx, command, tm.workdir(), packageTimeout, decision, nil, nil)
+	result.script = command
+	target := params.Name
+	if target == "" {
+		target = "all packages"
+	}
+	switch result.Status {
+	case "success":
+		result.Message = fmt.Sprintf("%s %s succeeded", params.Action, target)
+	case "error":
+		if params.Action == "query" {
+			result.Message = fmt.Sprintf("%s is not installed or could not be queried", target)
+		} else {
+			result.Message = fmt.Sprintf("%s %s failed: %s", params.Action, target, result.Message)
+		}
+	}
+	return result
+}
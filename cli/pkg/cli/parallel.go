@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// parallelResult is a tool call run ahead of the serial loop
+type parallelResult struct {
+	arguments string // The arguments after local JSON repair
+	result    TaskResponse
+}
+
+// readOnlyToolCall reports whether a tool call only reads state, so it can
+// run concurrently with others. Only informational run_commands without
+// redirection, chaining or substitution, package queries and http_fetch GETs
+// qualify; edits, installs, custom tools and everything else run serially.
+func readOnlyToolCall(name, arguments string) bool {
+	var params struct {
+		Command string `json:"command"`
+		Action  string `json:"action"`
+		Method  string `json:"method"`
+	}
+	if json.Unmarshal([]byte(arguments), &params) != nil {
+		return false
+	}
+	switch name {
+	case "run_commands":
+		return isInfoCommand(params.Command) &&
+			!strings.ContainsAny(params.Command, "<>;&|`$\n") &&
+			!strings.Contains(params.Command, "-exec") && !strings.Contains(params.Command, "-delete")
+	case "manage_package":
+		return params.Action == "query"
+	case "http_fetch":
+		return params.Method == "" || strings.EqualFold(params.Method, "GET")
+	}
+	return false
+}
+
+// runReadOnlyInParallel executes the unbroken run of read-only calls that
+// starts at tool call from, within the first limit, with at most workers
+// running at once, and returns their results by position. It stops at the
+// first call that may change state, so that call and any read after it see
+// the calls before them finished. The caller still reports, logs and rates
+// every call in the order the model sent them. A call whose arguments need
+// more than a local repair, or fail validation, also ends the run and is left
+// to the serial loop.
+func (tm *TaskManager) runReadOnlyInParallel(ctx context.Context, toolCalls []common.ToolCall, from, limit, workers int) map[int]parallelResult {
+	var indexes []int
+	arguments := make(map[int]string)
+	for i := from; i < len(toolCalls); i++ {
+		if limit > 0 && i >= limit {
+			break
+		}
+		toolCall := toolCalls[i]
+		args, ok := repairJSON(toolCall.Function.Arguments)
+		if !ok || !readOnlyToolCall(toolCall.Function.Name, args) {
+			break
+		}
+		toolCall.Function.Arguments = args
+		if tm.validateToolCall(toolCall).Status != "" {
+			break
+		}
+		indexes = append(indexes, i)
+		arguments[i] = args
+	}
+	if len(indexes) < 2 {
+		return nil
+	}
+
+	tm.progressf("⚡ Running %d read-only tool call(s) in parallel\n", len(indexes))
+	results := make(map[int]parallelResult, len(indexes))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, workers)
+	for _, i := range indexes {
+		toolCall := toolCalls[i]
+		toolCall.Function.Arguments = arguments[i]
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			result := tm.dispatchRecovered(ctx, toolCall)
+			mu.Lock()
+			results[i] = parallelResult{arguments: toolCall.Function.Arguments, result: result}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// acquireCommandSlot waits until fewer than MaxParallelCommands commands are
+// running and returns the function that frees the slot again. It fails only
+// when ctx ends while waiting.
+func (tm *TaskManager) acquireCommandSlot(ctx context.Context) (func(), error) {
+	if tm.commandSlots == nil {
+		return func() {}, nil
+	}
+	select {
+	case tm.commandSlots <- struct{}{}:
+	default:
+		tm.verbosef("Waiting for one of %d command slot(s)", cap(tm.commandSlots))
+		select {
+		case tm.commandSlots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return func() { <-tm.commandSlots }, nil
+}
+
+// dispatchRecovered runs a tool call, turning a panic into an error result so
+// one failing worker does not take down the others unlogged
+func (tm *TaskManager) dispatchRecovered(ctx context.Context, toolCall common.ToolCall) (result TaskResponse) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = TaskResponse{Status: "error", Message: fmt.Sprintf("panic: %v", r)}
+		}
+	}()
+	return tm.dispatchTool(ctx, toolCall)
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+func TestParallelToolsWaitForEarlierMutatingCall(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	call := func(id, command string) common.ToolCall {
+		return common.ToolCall{
+			ID:       id,
+			Type:     "function",
+			Function: common.FunctionCall{Name: "run_commands", Arguments: `{"command": "` + command + `"}`},
+		}
+	}
+	client := &scriptedClient{response: common.Message{
+		Role:      "assistant",
+		ToolCalls: []common.ToolCall{call("c1", "touch made"), call("c2", "ls made"), call("c3", "ls made")},
+	}}
+	var statuses []string
+	tm := NewTaskManager("", "test-model", true, false, TaskOptions{
+		NoRate:        true,
+		Quiet:         true,
+		ParallelTools: 4,
+		OnToolResult: func(tool string, result TaskResponse) {
+			statuses = append(statuses, result.Status+": "+result.Message)
+		},
+	})
+	tm.tinyllamaClient = client
+
+	if err := tm.ExecuteTask(context.Background(), "make a file and list it"); err != nil {
+		t.Fatalf("ExecuteTask: %v (results %q)", err, statuses)
+	}
+	if len(statuses) != 3 {
+		t.Fatalf("got %d results, want 3: %q", len(statuses), statuses)
+	}
+	for i, status := range statuses {
+		if !strings.HasPrefix(status, "success") {
+			t.Errorf("call %d: %s", i+1, status)
+		}
+	}
+}
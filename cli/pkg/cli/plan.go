@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// showPlan prints the tool calls the model proposed without executing them.
+// When stdin is a terminal the user may choose to go ahead with execution;
+// it returns true in that case.
+func (tm *TaskManager) showPlan(ctx context.Context, message common.Message) bool {
+	if len(message.ToolCalls) == 0 {
+		fmt.Println("📋 Plan: the model proposed no tool calls")
+		if message.Content != "" {
+			fmt.Printf("💬 Answer:\n%s\n", message.Content)
+		}
+		return false
+	}
+
+	fmt.Printf("📋 Plan: the model proposes %d tool call(s)\n", len(message.ToolCalls))
+	printToolCalls(message.ToolCalls)
+
+	if !isTerminal(os.Stdin) {
+		fmt.Println("📋 Plan only, nothing was executed")
+		return false
+	}
+	if !confirm(ctx, "Execute this plan?") {
+		fmt.Println("📋 Plan not executed")
+		return false
+	}
+	return true
+}
+
+// printToolCalls lists tool calls with their pretty-printed arguments
+func printToolCalls(toolCalls []common.ToolCall) {
+	for i, toolCall := range toolCalls {
+		fmt.Printf("\n%d. %s\n", i+1, toolCall.Function.Name)
+		fmt.Println(indent(prettyArguments(toolCall.Function.Arguments), "   "))
+	}
+	fmt.Println()
+}
+
+// indent prefixes every line of s with prefix
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+// prettyArguments pretty-prints JSON arguments, falling back to the raw text
+func prettyArguments(arguments string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(arguments), &v); err != nil {
+		return arguments
+	}
+	pretty, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return arguments
+	}
+	return string(pretty)
+}
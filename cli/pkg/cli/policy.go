@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadPatternFile reads command patterns, one per line. Blank lines and
+// lines starting with # are ignored.
+func LoadPatternFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return patterns, nil
+}
+
+// deniedReason returns why a command may not run, or an empty string when it
+// may. Commands are checked against the built-in dangerous patterns, in safe
+// mode the strict ones, then the configured deny patterns (matched anywhere
+// in the command) and, when an allowlist is configured, must start with one
+// of its entries.
+func (tm *TaskManager) deniedReason(command string) string {
+	if pattern := dangerousPattern(command); pattern != "" {
+		return fmt.Sprintf("matched pattern '%s'", pattern)
+	}
+	if tm.options.Safe {
+		if pattern := strictPattern(command); pattern != "" {
+			return fmt.Sprintf("matched safe mode pattern '%s' (--unsafe allows it)", pattern)
+		}
+	}
+
+	lower := strings.ToLower(command)
+	for _, pattern := range tm.options.DenyPatterns {
+		if strings.Contains(lower, strings.ToLower(pattern)) {
+			return fmt.Sprintf("matched deny pattern '%s'", pattern)
+		}
+	}
+
+	if len(tm.options.AllowPatterns) == 0 {
+		return ""
+	}
+	trimmed := strings.TrimSpace(lower)
+	for _, pattern := range tm.options.AllowPatterns {
+		if strings.HasPrefix(trimmed, strings.ToLower(pattern)) {
+			return ""
+		}
+	}
+	return "not on the allowlist"
+}
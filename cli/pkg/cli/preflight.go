@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// preflightTimeout bounds the startup connectivity check so a dead endpoint
+// fails fast instead of waiting for the full chat timeout
+const preflightTimeout = 5 * time.Second
+
+// preflight checks that the endpoint is reachable and serves the configured
+// model (or one of its fallbacks) before any chat request is sent
+func (tm *TaskManager) preflight(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+
+	list, err := tm.tinyllamaClient.ListModels(ctx)
+	if err != nil {
+		return fmt.Errorf("preflight: cannot list models at %s (check --url or TINYLLAMA_URL): %w",
+			tm.tinyllamaClient.BaseURL(), err)
+	}
+	available := list.Names()
+
+	if slices.Contains(available, tm.model) {
+		tm.verbosef("Preflight: model %s is available", tm.model)
+		return nil
+	}
+	for _, model := range tm.options.ModelFallback {
+		if slices.Contains(available, model) {
+			tm.warnf("⚠️  Model %s is not available, will fall back to %s\n", tm.model, model)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("preflight: model %s is not available at %s (available: %s); pull it first, e.g. `ollama pull %s`",
+		tm.model, tm.tinyllamaClient.BaseURL(), strings.Join(available, ", "), tm.model)
+}
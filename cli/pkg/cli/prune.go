@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Log rotation policies, chosen with log_rotation in the config file
+const (
+	LogRotationOldest = "oldest" // Drop the oldest entries
+	LogRotationRated  = "rated"  // Drop the lowest-rated entries, oldest first among equals
+)
+
+// logRotation is the policy logToolCall applies when the log is full
+var logRotation = LogRotationOldest
+
+// SetLogRotation selects which entries are dropped when tool_calls.log
+// exceeds its maximum size; "" keeps the default
+func SetLogRotation(policy string) error {
+	if err := validateLogRotation(policy); err != nil {
+		return err
+	}
+	if policy != "" {
+		logRotation = policy
+	}
+	return nil
+}
+
+func validateLogRotation(policy string) error {
+	switch policy {
+	case "", LogRotationOldest, LogRotationRated:
+		return nil
+	}
+	return fmt.Errorf("invalid log_rotation %q: use %q or %q", policy, LogRotationOldest, LogRotationRated)
+}
+
+// rotateLogs trims logs to at most max entries using the rotation policy.
+// The kept entries stay in their original order.
+func rotateLogs(logs []ToolCallLog, max int) []ToolCallLog {
+	excess := len(logs) - max
+	if excess <= 0 {
+		return logs
+	}
+	if logRotation != LogRotationRated {
+		return logs[excess:]
+	}
+
+	// Unrated entries count as 0 and go first; the sort is stable, so ties
+	// drop the oldest
+	order := make([]int, len(logs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return logs[order[a]].Rating < logs[order[b]].Rating })
+	drop := make(map[int]bool, excess)
+	for _, i := range order[:excess] {
+		drop[i] = true
+	}
+	kept := make([]ToolCallLog, 0, max)
+	for i, entry := range logs {
+		if !drop[i] {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// PruneOptions selects the log entries PruneLog keeps. An entry is kept only
+// when it passes every criterion that is set.
+type PruneOptions struct {
+	MinRating   int       // Keep entries rated at least this; 0 disables the check
+	Since       time.Time // Keep entries logged at or after this time
+	Before      time.Time // Keep entries logged before this time
+	SuccessOnly bool      // Keep only successful tool calls
+	DryRun      bool      // Report what would be removed without writing
+	Yes         bool      // Do not ask before rewriting the log
+}
+
+// keeps reports whether an entry passes the criteria
+func (o PruneOptions) keeps(entry ToolCallLog) bool {
+	return (o.MinRating == 0 || entry.Rating >= o.MinRating) &&
+		(o.Since.IsZero() || !entry.Timestamp.Before(o.Since)) &&
+		(o.Before.IsZero() || entry.Timestamp.Before(o.Before)) &&
+		(!o.SuccessOnly || entry.Status == "success")
+}
+
+// PruneLog rewrites tool_calls.log keeping only the entries that match the
+// criteria. The log is replaced atomically, and only after confirmation
+// unless opts.Yes is set.
+func PruneLog(opts PruneOptions) error {
+	if opts.MinRating == 0 && opts.Since.IsZero() && opts.Before.IsZero() && !opts.SuccessOnly {
+		return fmt.Errorf("no criteria given; use -min-rating, -since, -before or -success")
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	logPath := getLogPath()
+	logs, err := readToolCallLogs(logPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", logPath, err)
+	}
+	var kept []ToolCallLog
+	for _, entry := range logs {
+		if opts.keeps(entry) {
+			kept = append(kept, entry)
+		}
+	}
+
+	removed := len(logs) - len(kept)
+	if removed == 0 {
+		fmt.Printf("✂️  All %d entries match; nothing to prune\n", len(logs))
+		return nil
+	}
+	if opts.DryRun {
+		fmt.Printf("✂️  Would keep %d of %d entries and remove %d\n", len(kept), len(logs), removed)
+		return nil
+	}
+	if !opts.Yes {
+		ctx, stop := signalContext()
+		defer stop()
+		if !confirm(ctx, fmt.Sprintf("Remove %d of %d entries from %s?", removed, len(logs), logPath)) {
+			fmt.Println("✂️  Log left unchanged")
+			return nil
+		}
+	}
+
+	if err := writeToolCallLogs(logPath, kept); err != nil {
+		return fmt.Errorf("failed to write %s: %w", logPath, err)
+	}
+	printSuccess("✂️  Kept %d of %d entries, removed %d\n", len(kept), len(logs), removed)
+	return nil
+}
+
+// ParseLogTime parses a -since or -before value: a duration back from now
+// such as 36h or 7d, a date (2006-01-02) or an RFC 3339 timestamp
+func ParseLogTime(value string) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use a duration such as 36h or 7d, a date (2006-01-02) or an RFC 3339 timestamp", value)
+}
@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// maxArgumentRepairAttempts caps how often the model is asked to resend a
+// tool call whose arguments could not be parsed or repaired locally
+const maxArgumentRepairAttempts = 2
+
+// repairJSON tries to fix the JSON breakages small models commonly produce:
+// double-encoded arguments, markdown fences, raw newlines inside strings,
+// single-quoted strings and trailing commas. It returns the repaired text and whether it is now valid.
+func repairJSON(s string) (string, bool) {
+	s = unwrapDoubleEncoded(strings.TrimSpace(s))
+	if json.Valid([]byte(s)) {
+		return s, true
+	}
+
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```json")
+		s = strings.TrimPrefix(s, "```")
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+		s = strings.TrimSpace(s)
+	}
+
+	for _, fix := range []func(string) string{
+		convertSingleQuotes,
+		escapeControlCharsInStrings,
+		removeTrailingCommas,
+	} {
+		s = fix(s)
+		if json.Valid([]byte(s)) {
+			return s, true
+		}
+	}
+	return s, false
+}
+
+// unwrapDoubleEncoded returns the inner text when s is a JSON string that
+// itself holds JSON, e.g. "{\"command\": \"ls\"}" encoded twice
+func unwrapDoubleEncoded(s string) string {
+	for strings.HasPrefix(s, `"`) {
+		var inner string
+		if err := json.Unmarshal([]byte(s), &inner); err != nil {
+			return s
+		}
+		inner = strings.TrimSpace(inner)
+		if !strings.HasPrefix(inner, "{") && !strings.HasPrefix(inner, `"`) {
+			return s
+		}
+		s = inner
+	}
+	return s
+}
+
+// escapeControlCharsInStrings escapes raw newlines, carriage returns and tabs
+// that appear inside double-quoted strings
+func escapeControlCharsInStrings(s string) string {
+	var b strings.Builder
+	inString, escaped := false, false
+	for _, r := range s {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == '"':
+				inString = false
+			case r == '\n':
+				b.WriteString(`\n`)
+				continue
+			case r == '\r':
+				b.WriteString(`\r`)
+				continue
+			case r == '\t':
+				b.WriteString(`\t`)
+				continue
+			}
+		} else if r == '"' {
+			inString = true
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// convertSingleQuotes rewrites single-quoted strings as double-quoted ones,
+// escaping any double quotes they contain
+func convertSingleQuotes(s string) string {
+	var b strings.Builder
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		if quote == 0 {
+			switch r {
+			case '"':
+				quote = '"'
+			case '\'':
+				quote = '\''
+				r = '"'
+			}
+			b.WriteRune(r)
+			continue
+		}
+
+		switch {
+		case escaped:
+			escaped = false
+			if quote == '\'' && r == '\'' {
+				// \' is not a valid JSON escape; drop the backslash
+				str := b.String()
+				b.Reset()
+				b.WriteString(str[:len(str)-1])
+			}
+		case r == '\\':
+			escaped = true
+		case r == quote:
+			quote = 0
+			r = '"'
+		case quote == '\'' && r == '"':
+			b.WriteString(`\"`)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// removeTrailingCommas drops commas that directly precede a closing brace or
+// bracket, ignoring string contents
+func removeTrailingCommas(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	inString, escaped := false, false
+	for i, r := range runes {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == '"':
+				inString = false
+			}
+			b.WriteRune(r)
+			continue
+		}
+		if r == '"' {
+			inString = true
+		}
+		if r == ',' {
+			j := i + 1
+			for j < len(runes) && strings.ContainsRune(" \t\r\n", runes[j]) {
+				j++
+			}
+			if j < len(runes) && (runes[j] == '}' || runes[j] == ']') {
+				continue
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// repairArguments returns valid JSON arguments for a tool call. It first
+// repairs common breakages locally and, if that fails, tells the model its
+// arguments were invalid and asks it to resend the call.
+func (tm *TaskManager) repairArguments(ctx context.Context, model string, messages []common.Message, tools []common.Tool, assistant common.Message, toolCall common.ToolCall) (string, error) {
+	args := toolCall.Function.Arguments
+	for attempt := 0; ; attempt++ {
+		repaired, ok := repairJSON(args)
+		if ok {
+			if repaired != toolCall.Function.Arguments && tm.debugMode {
+				fmt.Printf("🐛 DEBUG - Repaired tool arguments: %q -> %q\n", toolCall.Function.Arguments, repaired)
+			}
+			return repaired, nil
+		}
+
+		var v interface{}
+		parseErr := json.Unmarshal([]byte(args), &v)
+		if attempt >= maxArgumentRepairAttempts {
+			return "", fmt.Errorf("arguments are not valid JSON after %d repair attempt(s): %v", attempt, parseErr)
+		}
+
+		tm.progressf("🔁 Tool arguments for %s were malformed, asking the model to resend (attempt %d/%d)\n",
+			toolCall.Function.Name, attempt+1, maxArgumentRepairAttempts)
+
+		followUp := append([]common.Message{}, messages...)
+		followUp = append(followUp, assistant, common.Message{
+			Role:       "tool",
+			ToolCallID: toolCall.ID,
+			Content: fmt.Sprintf("Error: the arguments for your %s tool call were not valid JSON (%v). "+
+				"Resend the %s tool call with the arguments as a valid JSON object.",
+				toolCall.Function.Name, parseErr, toolCall.Function.Name),
+		})
+
+		resp, _, err := tm.chat(ctx, &common.ChatRequest{
+			Model:    model,
+			Messages: followUp,
+			Tools:    tools,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to request corrected arguments: %w", err)
+		}
+		if len(resp.Choices) == 0 {
+			return "", fmt.Errorf("no response when requesting corrected arguments")
+		}
+
+		resent := resp.Choices[0].Message
+		if len(resent.ToolCalls) == 0 && resent.Content != "" {
+			resent.ToolCalls = tm.extractToolCallsFromContent(resent.Content)
+		}
+		found := false
+		for _, tc := range resent.ToolCalls {
+			if tc.Function.Name == toolCall.Function.Name {
+				args = tc.Function.Arguments
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("model did not resend the %s tool call", toolCall.Function.Name)
+		}
+	}
+}
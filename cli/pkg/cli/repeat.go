@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// RunTaskRepeated runs the same query count times, e.g. to collect several
+// model responses for training data. Each run is logged on its own and
+// interactive rating is skipped. When options.Seed is set, run i uses the
+// seed plus i so the answers differ but stay reproducible.
+func RunTaskRepeated(query string, tinyllamaURL string, model string, toolsEnabled, debugMode bool, options TaskOptions, count int) (err error) {
+	// Record the run so `list` shows it and `cancel` can stop it. A detached
+	// run was registered by the process that started it.
+	id := os.Getenv(TaskIDEnv)
+	if id == "" {
+		task, regErr := registerTask(query, false)
+		if regErr != nil {
+			slog.Warn("failed to register task", "error", regErr)
+		}
+		id = task.ID
+	}
+	if id != "" {
+		defer func() {
+			if finishErr := finishTask(id, err); finishErr != nil {
+				slog.Warn("failed to record task status", "task", id, "error", finishErr)
+			}
+		}()
+	}
+
+	if count <= 1 {
+		return RunTask(query, tinyllamaURL, model, toolsEnabled, debugMode, options)
+	}
+
+	options.NoRate = true
+	manager, err := NewTaskManagerWithDefaults(tinyllamaURL, model, toolsEnabled, debugMode, options)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	if options.Preflight {
+		if err := manager.preflight(ctx); err != nil {
+			return err
+		}
+	}
+
+	succeeded, failed := 0, 0
+	for i := 0; i < count; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		if options.Seed != nil {
+			seed := *options.Seed + i
+			manager.options.Seed = &seed
+			fmt.Printf("\n━━━ [%d/%d] seed %d\n", i+1, count, seed)
+		} else {
+			fmt.Printf("\n━━━ [%d/%d]\n", i+1, count)
+		}
+		if err := manager.ExecuteTask(ctx, query); err != nil {
+			if errors.Is(err, ErrCancelled) {
+				break
+			}
+			printWarning("⚠️  Run %d failed: %v\n", i+1, err)
+			failed++
+		} else {
+			succeeded++
+		}
+	}
+
+	skipped := count - succeeded - failed
+	fmt.Printf("\n📊 %d run(s): %d succeeded, %d failed", count, succeeded, failed)
+	if skipped > 0 {
+		fmt.Printf(", %d not run", skipped)
+	}
+	fmt.Println()
+
+	if ctx.Err() != nil {
+		return ErrCancelled
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d runs failed", failed, count)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// ReplayLogEntry re-executes the tool call recorded at the given position of
+// tool_calls.log (1-based, as numbered by the log command). The call goes
+// through the same validation and safety checks as a model-proposed one and
+// is only run after confirmation, unless options.Yes is set.
+func ReplayLogEntry(index int, options TaskOptions) error {
+	logPath := getLogPath()
+	logs, err := readToolCallLogs(logPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", logPath, err)
+	}
+	if index < 1 || index > len(logs) {
+		return fmt.Errorf("no log entry %d (the log has %d entries)", index, len(logs))
+	}
+	entry := logs[index-1]
+
+	manager, err := NewTaskManagerWithDefaults("", "", true, false, options)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signalContext()
+	defer stop()
+
+	fmt.Printf("🔁 Replaying entry %d from %s\n", index, entry.Timestamp.Local().Format("2006-01-02 15:04:05"))
+	if entry.UserQuery != "" {
+		fmt.Printf("❓ Query: %s\n", entry.UserQuery)
+	}
+	fmt.Printf("📊 Original result: %s - %s\n", colorStatus(entry.Status), entry.Message)
+	toolCall := common.ToolCall{
+		Type:     "function",
+		Function: common.FunctionCall{Name: entry.ToolName, Arguments: entry.Arguments},
+	}
+	printToolCalls([]common.ToolCall{toolCall})
+
+	if !options.Yes && !confirm(ctx, "Run this again?") {
+		fmt.Println("🔁 Not replayed")
+		return nil
+	}
+
+	result := manager.validateToolCall(toolCall)
+	if result.Status == "" {
+		result = manager.dispatchTool(ctx, toolCall)
+	}
+	manager.printResult(result)
+	logToolResult(entry.ToolName, result)
+
+	switch result.Status {
+	case "success":
+		return nil
+	case "cancelled":
+		return ErrCancelled
+	default:
+		return ErrToolFailed
+	}
+}
+
+// ExportScript prints a bash script of the commands from successful
+// run_commands entries whose query matches pattern, oldest first
+func ExportScript(pattern string) error {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return fmt.Errorf("invalid query pattern: %w", err)
+	}
+	logPath := getLogPath()
+	logs, err := readToolCallLogs(logPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", logPath, err)
+	}
+
+	var b strings.Builder
+	count := 0
+	lastQuery, lastCommand := "", ""
+	for _, entry := range logs {
+		if entry.ToolName != "run_commands" || entry.Status != "success" || !re.MatchString(entry.UserQuery) {
+			continue
+		}
+		var args struct {
+			Command string `json:"command"`
+			Cwd     string `json:"cwd"`
+			User    string `json:"user"`
+		}
+		if json.Unmarshal([]byte(entry.Arguments), &args) != nil || args.Command == "" {
+			continue
+		}
+
+		command := args.Command
+		if args.User != "" {
+			command = fmt.Sprintf("sudo -u %s bash -c %s", shellQuote(args.User), shellQuote(command))
+		}
+		if args.Cwd != "" {
+			command = fmt.Sprintf("(cd %s && %s)", shellQuote(args.Cwd), command)
+		}
+		// Retries of the same command within a query only need to run once
+		if entry.UserQuery == lastQuery && command == lastCommand {
+			continue
+		}
+		if entry.UserQuery != lastQuery {
+			fmt.Fprintf(&b, "\n# %s\n", strings.Join(strings.Fields(entry.UserQuery), " "))
+			lastQuery = entry.UserQuery
+		}
+		b.WriteString(command + "\n")
+		lastCommand = command
+		count++
+	}
+	if count == 0 {
+		return fmt.Errorf("no successful commands found for queries matching %q", pattern)
+	}
+
+	fmt.Println("#!/bin/bash")
+	fmt.Printf("# %d command(s) from tool_calls.log for queries matching %q\n", count, pattern)
+	fmt.Println("set -euo pipefail")
+	fmt.Print(b.String())
+	return nil
+}
+
+// shellQuote quotes s as a single bash word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
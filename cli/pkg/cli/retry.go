@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// strictToolPrompt follows a prose answer when the model was expected to act
+const strictToolPrompt = "You must respond with a tool_call. Do not explain or describe the command; call the tool that carries out the request."
+
+// actionVerbs start queries that ask for something to be done or looked up
+// on this system, as opposed to explained
+var actionVerbs = []string{
+	"add", "change", "check", "configure", "count", "create", "delete", "disable",
+	"display", "enable", "find", "fix", "get", "install", "kill", "list", "make",
+	"mount", "move", "open", "print", "reload", "remove", "rename", "restart",
+	"run", "set", "show", "start", "stop", "uninstall", "update", "upgrade",
+}
+
+// needsExecution reports whether a query clearly asks for an action, i.e. it
+// starts with an imperative such as "install", "show" or "restart"
+func needsExecution(query string) bool {
+	fields := strings.Fields(strings.ToLower(query))
+	if len(fields) > 0 && fields[0] == "please" {
+		fields = fields[1:]
+	}
+	return len(fields) > 0 && slices.Contains(actionVerbs, strings.Trim(fields[0], ",.:!"))
+}
+
+// retryForToolCall re-sends the conversation once after a prose answer, with
+// strictToolPrompt appended and tool_choice forcing a tool. It returns the
+// new reply and the model that served it, or ok false when the retry failed
+// or still called no tool, in which case the original answer stands.
+func (tm *TaskManager) retryForToolCall(ctx context.Context, req *common.ChatRequest, answer common.Message) (common.Message, string, bool) {
+	tm.progressf("🔁 No tool call for an action request, asking again\n")
+	retry := *req
+	retry.Messages = append(slices.Clone(req.Messages),
+		common.Message{Role: "assistant", Content: answer.Content},
+		common.Message{Role: "user", Content: strictToolPrompt})
+	retry.ToolChoice = common.NewToolChoice("required")
+
+	slog.Info("chat request sent", "model", req.Model, "messages", len(retry.Messages), "retry", true)
+	resp, model, err := tm.chat(ctx, &retry)
+	if err != nil {
+		slog.Warn("tool call retry failed", "model", req.Model, "error", err)
+		tm.verbosef("Retry failed: %v", err)
+		return answer, "", false
+	}
+	if len(resp.Choices) == 0 || len(resp.Choices[0].Message.ToolCalls) == 0 {
+		tm.verbosef("Retry produced no tool call either")
+		return answer, "", false
+	}
+	tm.verbosef("Retry produced %d tool call(s)", len(resp.Choices[0].Message.ToolCalls))
+	return resp.Choices[0].Message, model, true
+}
@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// reviewOutputLines is the number of output lines shown per entry during review
+const reviewOutputLines = 15
+
+// ReviewLog walks through the most recent tool_calls.log entries and lets the
+// user set or change ratings and mark entries for deletion. Changes are only
+// written back once the review finishes.
+func ReviewLog(limit int) error {
+	logPath := getLogPath()
+	logs, err := readToolCallLogs(logPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", logPath, err)
+	}
+	if len(logs) == 0 {
+		fmt.Println("📭 No entries to review")
+		return nil
+	}
+
+	start := 0
+	if limit > 0 && len(logs) > limit {
+		start = len(logs) - limit
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	ratings := make(map[int]int)
+	deleted := make(map[int]bool)
+
+review:
+	for i := start; i < len(logs); i++ {
+		entry := logs[i]
+		fmt.Printf("\n──── [%d/%d] %s ────\n", i-start+1, len(logs)-start, entry.Timestamp.Format("2006-01-02 15:04:05"))
+		fmt.Printf("🤖 Model: %s\n", entry.Model)
+		if entry.UserQuery != "" {
+			fmt.Printf("❓ Query: %s\n", entry.UserQuery)
+		}
+		fmt.Printf("🛠️  Tool: %s\n", entry.ToolName)
+		fmt.Printf("📥 Arguments: %s\n", entry.Arguments)
+		fmt.Printf("📊 Result: %s - %s\n", colorStatus(entry.Status), entry.Message)
+		if entry.Output != "" {
+			fmt.Printf("📤 Output:\n%s\n", truncateLines(entry.Output, reviewOutputLines))
+		}
+		if entry.Rating > 0 {
+			fmt.Printf("⭐ Current rating: %d/5\n", entry.Rating)
+		} else {
+			fmt.Printf("⭐ Current rating: unrated\n")
+		}
+
+		for {
+			fmt.Print("Action [1-5 rate, 0 clear rating, d delete, Enter keep, q finish]: ")
+			input, err := reader.ReadString('\n')
+			input = strings.TrimSpace(strings.ToLower(input))
+			if err != nil && input == "" {
+				break review
+			}
+
+			switch input {
+			case "":
+			case "q":
+				break review
+			case "d":
+				deleted[i] = true
+				fmt.Println("🗑️  Marked for deletion")
+			default:
+				rating, err := strconv.Atoi(input)
+				if err != nil || rating < 0 || rating > 5 {
+					printWarning("⚠️  Invalid input\n")
+					continue
+				}
+				ratings[i] = rating
+				delete(deleted, i)
+				fmt.Printf("⭐ Rating set to %d\n", rating)
+			}
+			break
+		}
+	}
+
+	if len(ratings) == 0 && len(deleted) == 0 {
+		fmt.Println("\n✅ No changes made")
+		return nil
+	}
+
+	// Re-read the log so entries appended while reviewing are preserved,
+	// and apply the changes by matching entries rather than by position
+	current, err := readToolCallLogs(logPath)
+	if err != nil {
+		return fmt.Errorf("failed to re-read %s: %w", logPath, err)
+	}
+
+	var updated []ToolCallLog
+	changed, removed := 0, 0
+	for _, entry := range current {
+		idx := -1
+		for i := start; i < len(logs); i++ {
+			if sameLogEntry(logs[i], entry) {
+				idx = i
+				break
+			}
+		}
+		if idx >= 0 && deleted[idx] {
+			removed++
+			continue
+		}
+		if rating, ok := ratings[idx]; ok && idx >= 0 {
+			entry.Rating = rating
+			entry.RatingSource = "manual"
+			if rating == 0 {
+				entry.RatingSource = ""
+			}
+			changed++
+		}
+		updated = append(updated, entry)
+	}
+
+	if err := writeToolCallLogs(logPath, updated); err != nil {
+		return fmt.Errorf("failed to write %s: %w", logPath, err)
+	}
+
+	fmt.Printf("\n✅ Review saved: %d rating(s) updated, %d deleted\n", changed, removed)
+	return nil
+}
+
+// sameLogEntry reports whether two log entries describe the same tool call
+func sameLogEntry(a, b ToolCallLog) bool {
+	return a.Timestamp.Equal(b.Timestamp) &&
+		a.ToolName == b.ToolName &&
+		a.Arguments == b.Arguments &&
+		a.UserQuery == b.UserQuery
+}
+
+// truncateLines keeps at most n lines of s, noting how many were dropped
+func truncateLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) <= n {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines[:n], "\n") + fmt.Sprintf("\n... (%d more lines)", len(lines)-n)
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// runAsUser is the account a command is started as when it differs from the
+// current one
+type runAsUser struct {
+	name string
+	home string
+	cred *syscall.Credential
+}
+
+// resolveRunAs looks up the user a command should run as. It returns nil when
+// name is empty or is the current user. Switching to another user needs
+// root, since the child's credentials are set directly rather than via sudo.
+func resolveRunAs(name string) (*runAsUser, error) {
+	if name == "" {
+		return nil, nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		if _, convErr := strconv.Atoi(name); convErr != nil {
+			return nil, fmt.Errorf("unknown user %s", name)
+		}
+		if u, err = user.LookupId(name); err != nil {
+			return nil, fmt.Errorf("unknown user id %s", name)
+		}
+	}
+	if u.Uid == strconv.Itoa(os.Geteuid()) {
+		return nil, nil
+	}
+	if os.Geteuid() != 0 {
+		return nil, fmt.Errorf("running commands as %s requires root privileges", u.Username)
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("user %s has a non-numeric uid %s", u.Username, u.Uid)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("user %s has a non-numeric gid %s", u.Username, u.Gid)
+	}
+	cred := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	groupIDs, err := u.GroupIds()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list groups of %s: %w", u.Username, err)
+	}
+	for _, id := range groupIDs {
+		if g, err := strconv.ParseUint(id, 10, 32); err == nil {
+			cred.Groups = append(cred.Groups, uint32(g))
+		}
+	}
+	return &runAsUser{name: u.Username, home: u.HomeDir, cred: cred}, nil
+}
+
+// apply makes cmd start as the user, with HOME, USER and LOGNAME to match.
+// It does nothing on a nil receiver.
+func (r *runAsUser) apply(cmd *exec.Cmd) {
+	if r == nil {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = r.cred
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, "HOME="+r.home, "USER="+r.name, "LOGNAME="+r.name)
+}
@@ -0,0 +1,30 @@
+package cli
+
+import "strings"
+
+// strictDenyPatterns are refused in safe mode on top of the dangerous
+// patterns: recursive or forced deletes, wholesale permission and ownership
+// changes, stopping the machine or its services, account changes and running
+// code piped in from elsewhere. Like deny patterns they match anywhere in
+// the lowercased command.
+var strictDenyPatterns = []string{
+	"rm -r", "rm -f", "chmod -r", "chown -r", "chmod 777",
+	"shutdown", "reboot", "poweroff", "init 0", "init 6",
+	"systemctl stop", "systemctl disable", "systemctl mask",
+	"kill -9", "killall", "pkill",
+	"userdel", "groupdel", "chpasswd", "visudo", "crontab -r",
+	"iptables -f", "nft flush",
+	"| sh", "|sh", "| bash", "|bash", "> /dev/sd", "> /etc/",
+}
+
+// strictPattern returns the safe mode pattern the command matches, or an
+// empty string when it matches none
+func strictPattern(command string) string {
+	command = strings.ToLower(command)
+	for _, pattern := range strictDenyPatterns {
+		if strings.Contains(command, pattern) {
+			return pattern
+		}
+	}
+	return ""
+}
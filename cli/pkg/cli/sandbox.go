@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// resolveRoot validates the allowed filesystem root and returns its absolute,
+// symlink-free form
+func resolveRoot(root string) (string, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid root %s: %w", root, err)
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", fmt.Errorf("invalid root %s: %w", root, err)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("invalid root %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("invalid root %s: not a directory", root)
+	}
+	return resolved, nil
+}
+
+// resolveToolPath turns a path supplied by the model into an absolute path.
+// When an allowed root is configured, symlinks are resolved and any path that
+// escapes the root is rejected.
+func (tm *TaskManager) resolveToolPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(tm.workdir(), path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if tm.options.Root == "" {
+		return abs, nil
+	}
+
+	resolved, err := evalSymlinksAllowMissing(abs)
+	if err != nil {
+		return "", err
+	}
+	if !isWithin(tm.options.Root, resolved) {
+		return "", fmt.Errorf("path %s is outside the allowed root %s", path, tm.options.Root)
+	}
+	return resolved, nil
+}
+
+// workdir returns the directory commands run in: --workdir when set,
+// otherwise the process working directory
+func (tm *TaskManager) workdir() string {
+	if tm.options.Workdir != "" {
+		return tm.options.Workdir
+	}
+	return getCurrentDirectory()
+}
+
+// resolveDir resolves a working directory relative to workdir and checks
+// that it exists and, when an allowed root is configured, lies inside it
+func (tm *TaskManager) resolveDir(dir string) (string, error) {
+	path, err := tm.resolveToolPath(dir)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+	return path, nil
+}
+
+// evalSymlinksAllowMissing resolves symlinks in path like filepath.EvalSymlinks,
+// but also accepts paths whose trailing components do not exist yet by
+// resolving the deepest existing ancestor
+func evalSymlinksAllowMissing(path string) (string, error) {
+	var missing []string
+	current := filepath.Clean(path)
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(current))
+		current = parent
+	}
+}
+
+// isWithin reports whether path is root or lies beneath it
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// validateCommandWrapper checks that the wrapper program can be found
+func validateCommandWrapper(wrapper string) error {
+	fields := strings.Fields(wrapper)
+	if len(fields) == 0 {
+		return fmt.Errorf("command wrapper is empty")
+	}
+	if _, err := exec.LookPath(fields[0]); err != nil {
+		return fmt.Errorf("command wrapper %q not found: %w", fields[0], err)
+	}
+	return nil
+}
+
+// resolveShell picks the shell commands run with: the one named by --shell,
+// which must exist, otherwise bash, or sh on systems that lack bash. It
+// fails when there is none, so a stripped-down image is reported at startup
+// rather than by the first command.
+func resolveShell(name string) (string, error) {
+	if name != "" {
+		if _, err := exec.LookPath(name); err != nil {
+			return "", fmt.Errorf("shell %q not found: %w", name, err)
+		}
+		return name, nil
+	}
+	for _, shell := range []string{"bash", "sh"} {
+		if _, err := exec.LookPath(shell); err == nil {
+			if shell != "bash" {
+				slog.Warn("bash not found, running commands with sh", "shell", shell)
+			}
+			return shell, nil
+		}
+	}
+	return "", fmt.Errorf("no shell found: neither bash nor sh is in PATH; pass --shell")
+}
+
+// shell returns the shell commands run with, bash unless resolved otherwise
+func (tm *TaskManager) shell() string {
+	if tm.options.Shell == "" {
+		return "bash"
+	}
+	return tm.options.Shell
+}
+
+// buildCommand creates the process for a shell command, prefixed with the
+// configured command wrapper. The command itself is passed to the shell untouched.
+// It runs in its own process group, which is killed as a whole when ctx ends,
+// so background children and pipelines do not outlive a timeout.
+func (tm *TaskManager) buildCommand(ctx context.Context, command string) *exec.Cmd {
+	args := strings.Fields(tm.options.CommandWrapper)
+	args = append(args, tm.shell(), "-c", command)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = tm.commandEnv()
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
+
+// commandWaitDelay bounds how long a killed command may keep its output open
+const commandWaitDelay = 2 * time.Second
+
+// minimalPath is the PATH commands get with --clean-env
+const minimalPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// commandEnv returns the environment for executed commands. It is nil, which
+// inherits everything, unless --clean-env or --env-passthrough restrict it:
+// then only PATH and the allowlisted variables are passed. Allowlist entries
+// ending in * match by prefix, e.g. LC_*.
+func (tm *TaskManager) commandEnv() []string {
+	if !tm.options.CleanEnv && len(tm.options.EnvPassthrough) == 0 {
+		return nil
+	}
+
+	env := []string{"PATH=" + minimalPath}
+	if !tm.options.CleanEnv {
+		env = []string{"PATH=" + os.Getenv("PATH")}
+	}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if name != "PATH" && envAllowed(name, tm.options.EnvPassthrough) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// envAllowed reports whether a variable name matches the allowlist
+func envAllowed(name string, allowlist []string) bool {
+	for _, pattern := range allowlist {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// validateArguments checks tool call arguments against the tool's JSON Schema
+// parameters. It supports the subset of JSON Schema used by the tool
+// definitions: object properties, required fields, primitive types and enums.
+// It returns a list of human readable violations.
+func validateArguments(schema map[string]interface{}, arguments string) []string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(arguments), &value); err != nil {
+		return []string{fmt.Sprintf("arguments are not valid JSON: %v", err)}
+	}
+	return validateValue(schema, value, "arguments")
+}
+
+func validateValue(schema map[string]interface{}, value interface{}, path string) []string {
+	var violations []string
+
+	if typ, ok := schema["type"].(string); ok && !matchesType(typ, value) {
+		return []string{fmt.Sprintf("%s must be of type %s, got %s", path, typ, jsonType(value))}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			violations = append(violations, fmt.Sprintf("%s must be one of %v", path, enum))
+		}
+	}
+
+	obj, isObject := value.(map[string]interface{})
+	if !isObject {
+		return violations
+	}
+
+	for _, req := range requiredFields(schema) {
+		if _, ok := obj[req]; !ok {
+			violations = append(violations, fmt.Sprintf("missing required field %q", req))
+		}
+	}
+
+	props, _ := schema["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		propSchema, ok := props[k].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		violations = append(violations, validateValue(propSchema, obj[k], k)...)
+	}
+	return violations
+}
+
+// requiredFields returns the schema's required list, accepting both
+// []interface{} and []string
+func requiredFields(schema map[string]interface{}) []string {
+	switch req := schema["required"].(type) {
+	case []string:
+		return req
+	case []interface{}:
+		var fields []string
+		for _, r := range req {
+			if s, ok := r.(string); ok {
+				fields = append(fields, s)
+			}
+		}
+		return fields
+	}
+	return nil
+}
+
+func matchesType(typ string, value interface{}) bool {
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return strings.ToLower(fmt.Sprintf("%T", value))
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scriptRecorder collects the steps that succeeded for --save-script and
+// writes them as a bash script that repeats them. It spans every task the
+// manager runs, so a batch or --count produces one script; concurrent batch
+// workers record into it under mu, in the order their steps finish.
+type scriptRecorder struct {
+	path    string
+	workdir string
+
+	mu    sync.Mutex
+	body  strings.Builder
+	steps int
+	query string // Query of the last recorded step, which heads its section
+	last  string // Last recorded step, so a repeated call is written once
+}
+
+// add records a tool call that succeeded; failed, denied and skipped calls
+// and tools without a shell equivalent (http_fetch) are left out. It does
+// nothing on a nil recorder, so callers need not check for --save-script.
+func (s *scriptRecorder) add(query, tool string, step int, result TaskResponse) {
+	if s == nil || result.Status != "success" || result.script == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if query == s.query && result.script == s.last {
+		return
+	}
+	if query != s.query {
+		fmt.Fprintf(&s.body, "\n# Query: %s\n", strings.Join(strings.Fields(query), " "))
+		s.query = query
+	}
+	fmt.Fprintf(&s.body, "\n# Step %d: %s\n%s\n", step, tool, result.script)
+	s.last = result.script
+	s.steps++
+}
+
+// save writes the script, replacing the file, and makes it executable. It
+// returns how many steps the script has.
+func (s *scriptRecorder) save() (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.steps == 0 {
+		return 0, nil
+	}
+	var b strings.Builder
+	b.WriteString("#!/bin/bash\n")
+	fmt.Fprintf(&b, "# %d step(s) recorded by tinypenguin-cli on %s\n", s.steps, time.Now().Format("2006-01-02 15:04:05"))
+	b.WriteString("# Review before running: commands are repeated as they ran, and edits are\n# applied as patches that fail if the file has changed since.\n")
+	b.WriteString("set -euo pipefail\n")
+	fmt.Fprintf(&b, "cd %s\n", shellQuote(s.workdir))
+	b.WriteString(s.body.String())
+	return s.steps, os.WriteFile(s.path, []byte(b.String()), 0755)
+}
+
+// hereDoc renders text as a quoted here-document following command, with a
+// delimiter that does not occur in the text
+func hereDoc(command, delimiter, text string) string {
+	for n := 2; strings.Contains(text, delimiter); n++ {
+		delimiter = fmt.Sprintf("%s_%d", strings.TrimRight(delimiter, "_0123456789"), n)
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return fmt.Sprintf("%s <<'%s'\n%s%s", command, delimiter, text, delimiter)
+}
+
+// commandScript is the script line for a run_commands call as it ran: in
+// its directory, as its user and with its input
+func (tm *TaskManager) commandScript(command, cwd, user string, stdin *string) string {
+	if user != "" {
+		command = fmt.Sprintf("sudo -u %s %s -c %s", shellQuote(user), tm.shell(), shellQuote(command))
+	}
+	if cwd != "" {
+		command = fmt.Sprintf("(cd %s && %s)", shellQuote(cwd), command)
+	}
+	switch {
+	case stdin != nil:
+		return hereDoc(command, "TINYPENGUIN_STDIN", *stdin)
+	case tm.options.StdinFile != "":
+		return fmt.Sprintf("%s < %s", command, shellQuote(tm.options.StdinFile))
+	}
+	return command
+}
+
+// editScript is the script for an edit_files call: a patch for an existing
+// file, the whole content for a new one. A file with CRLF line endings, a
+// byte order mark or another encoding than UTF-8 is written whole, base64
+// encoded, since the patch is of its decoded text.
+func editScript(path, diff string, content []byte, created bool, format textFormat) string {
+	plain := format == textFormat{}
+	switch {
+	case created && plain:
+		return fmt.Sprintf("mkdir -p \"$(dirname %s)\"\n%s", shellQuote(path), hereDoc("cat > "+shellQuote(path), "TINYPENGUIN_FILE", string(content)))
+	case plain:
+		return hereDoc("patch --forward "+shellQuote(path), "TINYPENGUIN_PATCH", diff)
+	}
+	return fmt.Sprintf("# %s is %s, so its new content is written whole\n%s", path, format,
+		hereDoc("base64 -d > "+shellQuote(path), "TINYPENGUIN_FILE", wrapBase64(content)))
+}
+
+// wrapBase64 encodes data as base64 in lines of 76 characters
+func wrapBase64(data []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded)
+	return b.String()
+}
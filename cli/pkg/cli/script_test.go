@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestScriptRecorderConcurrentAdd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.sh")
+	s := &scriptRecorder{path: path, workdir: "/srv"}
+
+	var wg sync.WaitGroup
+	for worker := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for step := range 25 {
+				s.add(fmt.Sprintf("query %d", worker), "run_commands", step+1,
+					TaskResponse{Status: "success", script: fmt.Sprintf("echo %d-%d", worker, step)})
+			}
+		}()
+	}
+	wg.Wait()
+
+	steps, err := s.save()
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if steps != 100 {
+		t.Errorf("saved %d steps, want 100", steps)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for worker := range 4 {
+		for step := range 25 {
+			if line := fmt.Sprintf("\necho %d-%d\n", worker, step); !strings.Contains(string(data), line) {
+				t.Errorf("script lacks %q", strings.TrimSpace(line))
+			}
+		}
+	}
+}
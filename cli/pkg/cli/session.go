@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// Session is a conversation saved between invocations with --session. The
+// system prompt is not stored; it is rebuilt for every run.
+type Session struct {
+	Name     string           `json:"name"`
+	Created  time.Time        `json:"created"`
+	Updated  time.Time        `json:"updated"`
+	Messages []common.Message `json:"messages"`
+}
+
+var sessionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// sessionDir returns ~/.tinypenguin/sessions
+func sessionDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".tinypenguin", "sessions"), nil
+}
+
+// sessionPath returns the file a named session is stored in
+func sessionPath(name string) (string, error) {
+	if !sessionNamePattern.MatchString(name) || strings.Trim(name, ".") == "" {
+		return "", fmt.Errorf("invalid session name %q: use letters, digits, '.', '_' or '-'", name)
+	}
+	dir, err := sessionDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// loadSession reads a session, or starts a new one if it does not exist yet.
+// A file that does not hold a valid conversation is reported, not replaced.
+func loadSession(name string) (*Session, error) {
+	path, err := sessionPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		now := time.Now()
+		return &Session{Name: name, Created: now, Updated: now}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", name, err)
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, corruptSession(name, err)
+	}
+	if err := session.validate(); err != nil {
+		return nil, corruptSession(name, err)
+	}
+	session.Name = name
+	return &session, nil
+}
+
+func corruptSession(name string, err error) error {
+	return fmt.Errorf("session %s is corrupt (%v); delete it with `tinypenguin-cli sessions delete %s`", name, err, name)
+}
+
+// validate checks that the messages form a conversation that can be sent
+// back to the model
+func (s *Session) validate() error {
+	return validateMessages(s.Messages)
+}
+
+// validateMessages checks for known roles and tool results that answer a call
+func validateMessages(messages []common.Message) error {
+	callIDs := make(map[string]bool)
+	for i, msg := range messages {
+		switch msg.Role {
+		case "user":
+		case "assistant":
+			for _, tc := range msg.ToolCalls {
+				callIDs[tc.ID] = true
+			}
+		case "tool":
+			if !callIDs[msg.ToolCallID] {
+				return fmt.Errorf("message %d answers unknown tool call %q", i+1, msg.ToolCallID)
+			}
+		default:
+			return fmt.Errorf("message %d has unexpected role %q", i+1, msg.Role)
+		}
+	}
+	return nil
+}
+
+// add appends messages to the session. It does nothing on a nil session, so
+// callers need not check whether --session is in use.
+func (s *Session) add(messages ...common.Message) {
+	if s == nil {
+		return
+	}
+	for _, msg := range messages {
+		msg.Images = nil // Attachments are not kept between runs
+		s.Messages = append(s.Messages, msg)
+	}
+}
+
+// answerPendingCalls adds a "not executed" result for every tool call of the
+// last assistant message that has none, e.g. after a plan was declined or
+// the tool limit was hit. The API rejects calls left without a result.
+func (s *Session) answerPendingCalls() {
+	last := -1
+	for i, msg := range s.Messages {
+		if msg.Role == "assistant" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return
+	}
+	answered := make(map[string]bool)
+	for _, msg := range s.Messages[last+1:] {
+		if msg.Role == "tool" {
+			answered[msg.ToolCallID] = true
+		}
+	}
+	for _, tc := range s.Messages[last].ToolCalls {
+		if !answered[tc.ID] {
+			s.add(toolResultMessage(tc, TaskResponse{Status: "skipped", Message: "the tool call was not executed"}))
+		}
+	}
+}
+
+// save writes the session atomically, creating the sessions directory if needed
+func (s *Session) save() error {
+	s.answerPendingCalls()
+	path, err := sessionPath(s.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	s.Updated = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+s.Name+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// toolResultMessage reports a tool result back to the model
+func toolResultMessage(toolCall common.ToolCall, result TaskResponse) common.Message {
+	content := fmt.Sprintf("%s: %s", result.Status, result.Message)
+	if result.ErrorKind != "" {
+		content = fmt.Sprintf("%s [%s]: %s", result.Status, result.ErrorKind, result.Message)
+	}
+	if result.Output != "" {
+		content += "\n" + result.Output
+	}
+	return common.Message{Role: "tool", ToolCallID: toolCall.ID, Content: content}
+}
+
+// ListSessions prints the saved sessions, most recently used first
+func ListSessions() error {
+	dir, err := sessionDir()
+	if err != nil {
+		return err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	var sessions []*Session
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		session, err := loadSession(name)
+		if err != nil {
+			printWarning("⚠️  %v\n", err)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	if len(sessions) == 0 {
+		fmt.Println("📭 No saved sessions")
+		return nil
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Updated.After(sessions[j].Updated) })
+	fmt.Printf("%-24s  %-19s  %8s  %s\n", "NAME", "UPDATED", "MESSAGES", "FIRST QUERY")
+	for _, s := range sessions {
+		first := ""
+		for _, msg := range s.Messages {
+			if msg.Role == "user" {
+				first = summarizeArguments(msg.Content, 50)
+				break
+			}
+		}
+		fmt.Printf("%-24s  %-19s  %8d  %s\n", s.Name, s.Updated.Local().Format("2006-01-02 15:04:05"), len(s.Messages), first)
+	}
+	return nil
+}
+
+// DeleteSession removes a saved session
+func DeleteSession(name string) error {
+	path, err := sessionPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("no session named %s", name)
+		}
+		return err
+	}
+	fmt.Printf("🗑️  Deleted session %s\n", name)
+	return nil
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ANSI color codes used for status output
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+)
+
+// colorEnabled is on only for terminals and when NO_COLOR is unset
+var colorEnabled = isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+
+// DisableColor turns off colored output, e.g. for --no-color
+func DisableColor() {
+	colorEnabled = false
+}
+
+// colorize wraps s in an ANSI color when color output is enabled
+func colorize(color, s string) string {
+	if !colorEnabled {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// statusColor returns the color for a tool result status
+func statusColor(status string) string {
+	switch status {
+	case "success":
+		return ansiGreen
+	case "denied", "cancelled":
+		return ansiYellow
+	default:
+		return ansiRed
+	}
+}
+
+// colorStatus colors a tool result status word
+func colorStatus(status string) string {
+	return colorize(statusColor(status), status)
+}
+
+// printStyled prints a formatted line in the given color. The trailing
+// newline is kept outside the color codes.
+func printStyled(color, format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+	body := strings.TrimSuffix(line, "\n")
+	fmt.Print(colorize(color, body) + line[len(body):])
+}
+
+// progressf prints progress chatter, which --quiet suppresses
+func (tm *TaskManager) progressf(format string, args ...interface{}) {
+	if !tm.options.Quiet {
+		fmt.Print(tm.stamp() + fmt.Sprintf(format, args...))
+	}
+}
+
+// stamp returns the --timestamps prefix of a progress line: the time since
+// the task started and, in the tool loop, which call of the response is
+// running, e.g. "[step 2/3, +4.3s] ". It is empty without --timestamps.
+func (tm *TaskManager) stamp() string {
+	if !tm.options.Timestamps || tm.started.IsZero() {
+		return ""
+	}
+	elapsed := time.Since(tm.started).Seconds()
+	if tm.step > 0 {
+		return fmt.Sprintf("[step %d/%d, +%.1fs] ", tm.step, tm.steps, elapsed)
+	}
+	return fmt.Sprintf("[+%.1fs] ", elapsed)
+}
+
+// warnf prints a warning line; with --quiet it goes plainly to stderr
+func (tm *TaskManager) warnf(format string, args ...interface{}) {
+	if tm.options.Quiet {
+		fmt.Fprintf(os.Stderr, format, args...)
+		return
+	}
+	printWarning(tm.stamp()+"%s", fmt.Sprintf(format, args...))
+}
+
+// errorf prints an error line; with --quiet it goes plainly to stderr
+func (tm *TaskManager) errorf(format string, args ...interface{}) {
+	if tm.options.Quiet {
+		fmt.Fprintf(os.Stderr, format, args...)
+		return
+	}
+	printError(format, args...)
+}
+
+// printResult prints a tool result. Quiet mode prints only the output, and
+// the status message on stderr when the tool did not succeed.
+func (tm *TaskManager) printResult(result TaskResponse) {
+	if tm.options.Quiet {
+		if result.Status != "success" {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", result.Status, result.Message)
+		}
+		if output := result.displayOutput(); output != "" {
+			fmt.Print(output)
+			if !strings.HasSuffix(output, "\n") {
+				fmt.Println()
+			}
+		}
+		return
+	}
+	fmt.Printf("%s📊 Tool result: %s - %s\n", tm.stamp(), colorStatus(result.Status), result.Message)
+	if result.Output != "" {
+		fmt.Printf("📤 Output:\n%s\n", result.displayOutput())
+	}
+}
+
+// printSuccess, printError and printWarning print a formatted status line
+func printSuccess(format string, args ...interface{}) { printStyled(ansiGreen, format, args...) }
+func printError(format string, args ...interface{})   { printStyled(ansiRed, format, args...) }
+func printWarning(format string, args ...interface{}) { printStyled(ansiYellow, format, args...) }
+
+// ratingContextLines is how many lines from each end of the output the
+// rating prompt repeats
+const ratingContextLines = 3
+
+// printRatingContext repeats what is about to be rated, since the output may
+// have scrolled off: the call, its status and the first and last lines of
+// its output
+func printRatingContext(tool, arguments string, result TaskResponse) {
+	fmt.Printf("\n── %s: %s\n", tool, summarizeArguments(arguments, 70))
+	status := colorStatus(result.Status)
+	if result.ExitCode != nil {
+		status += fmt.Sprintf(" (exit %d)", *result.ExitCode)
+	}
+	output := strings.TrimRight(result.displayOutput(), "\n")
+	if output == "" {
+		fmt.Printf("   %s, no output\n", status)
+		return
+	}
+
+	lines := strings.Split(output, "\n")
+	fmt.Printf("   %s, %d line(s) of output\n", status, len(lines))
+	if len(lines) > 2*ratingContextLines {
+		omitted := len(lines) - 2*ratingContextLines
+		lines = append(append(lines[:ratingContextLines:ratingContextLines],
+			fmt.Sprintf("... %d more line(s) ...", omitted)), lines[len(lines)-ratingContextLines:]...)
+	}
+	for _, line := range lines {
+		fmt.Printf("   │ %s\n", truncateUTF8(line, 120))
+	}
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// shellFenceLanguages are the fenced code block languages treated as
+// commands; an unlabeled block counts as well
+var shellFenceLanguages = map[string]bool{
+	"": true, "bash": true, "sh": true, "shell": true, "zsh": true, "console": true, "shell-session": true,
+}
+
+var (
+	fenceOpen    = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([A-Za-z0-9_+-]*)")
+	inlineCode   = regexp.MustCompile("`([^`\n]+)`")
+	promptPrefix = regexp.MustCompile(`^(\$|#|[A-Za-z0-9_.-]+@[A-Za-z0-9_.-]+[^$#]*[$#])\s+`)
+)
+
+// extractSuggestedCommands returns the commands in a markdown answer, in
+// order and without duplicates: the lines of shell code blocks, with prompts,
+// comments and output removed, and inline code spans that start with a
+// program found on PATH.
+func extractSuggestedCommands(answer string) []string {
+	var commands []string
+	seen := make(map[string]bool)
+	add := func(command string) {
+		command = strings.TrimSpace(command)
+		if command != "" && !seen[command] {
+			seen[command] = true
+			commands = append(commands, command)
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(answer, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		m := fenceOpen.FindStringSubmatch(lines[i])
+		if m == nil {
+			for _, span := range inlineCode.FindAllStringSubmatch(lines[i], -1) {
+				if looksLikeCommand(span[1]) {
+					add(span[1])
+				}
+			}
+			continue
+		}
+
+		// Collect the block up to the matching closing fence
+		var block []string
+		for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), m[1]); i++ {
+			block = append(block, lines[i])
+		}
+		if shellFenceLanguages[strings.ToLower(m[2])] {
+			for _, command := range blockCommands(block) {
+				add(command)
+			}
+		}
+	}
+	return commands
+}
+
+// blockCommands returns the commands of a shell code block. When any line
+// starts with a prompt ($ or user@host$) only prompted lines are commands and
+// the rest is output; otherwise every line that is not a comment is, with
+// backslash continuations joined.
+func blockCommands(block []string) []string {
+	prompted := false
+	for _, line := range block {
+		if strings.HasPrefix(strings.TrimSpace(line), "$ ") {
+			prompted = true
+			break
+		}
+	}
+
+	var commands []string
+	var pending string
+	for _, line := range block {
+		line = strings.TrimSpace(line)
+		if prompted {
+			loc := promptPrefix.FindStringIndex(line)
+			if loc == nil {
+				continue
+			}
+			line = line[loc[1]:]
+		} else if pending == "" && (line == "" || strings.HasPrefix(line, "#")) {
+			continue
+		}
+		if continued, ok := strings.CutSuffix(line, "\\"); ok {
+			pending += strings.TrimSpace(continued) + " "
+			continue
+		}
+		commands = append(commands, pending+line)
+		pending = ""
+	}
+	if pending != "" {
+		commands = append(commands, strings.TrimSpace(pending))
+	}
+	return commands
+}
+
+// looksLikeCommand reports whether an inline code span is a command rather
+// than a path, package or option name: at least two words, the first one a
+// program on PATH (sudo is skipped)
+func looksLikeCommand(span string) bool {
+	fields := strings.Fields(span)
+	if len(fields) > 0 && fields[0] == "sudo" {
+		fields = fields[1:]
+	}
+	if len(fields) < 2 || strings.ContainsAny(fields[0], "/=") {
+		return false
+	}
+	_, err := exec.LookPath(fields[0])
+	return err == nil
+}
+
+// printSuggestedCommands lists the commands found in an advice-only answer
+func printSuggestedCommands(answer string) {
+	commands := extractSuggestedCommands(answer)
+	if len(commands) == 0 {
+		return
+	}
+	fmt.Println("\n📋 Suggested commands (not executed):")
+	for i, command := range commands {
+		fmt.Printf("  %d. %s\n", i+1, command)
+	}
+}
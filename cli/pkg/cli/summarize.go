@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// summaryInputBytes caps the output sent to be summarized; the middle of a
+// larger output is left out, since the start and end usually carry the
+// headers, errors and totals
+const summaryInputBytes = 16 << 10
+
+// summaryPrompt asks for the summary that replaces a large tool output
+const summaryPrompt = `Summarize the output of this %s call in at most 5 bullet points for an agent deciding its next step. Keep error messages, file paths, counts and values exactly as they appear. Reply with the bullet points only.
+
+Arguments: %s
+
+Output:
+%s`
+
+// summarizeOutput replaces a tool output larger than --summarize-output with
+// a short summary written by the model, so one noisy command does not crowd
+// the rest of the conversation out of its context. The full output is saved
+// to a file named in the summary and still shown on screen. If the summary
+// cannot be made, the output is left as it is.
+func (tm *TaskManager) summarizeOutput(ctx context.Context, tool, arguments string, result TaskResponse) TaskResponse {
+	full := result.displayOutput()
+	if tm.options.SummarizeOutput <= 0 || len(full) <= tm.options.SummarizeOutput {
+		return result
+	}
+
+	path, err := saveFullOutput(full)
+	if err != nil {
+		tm.warnf("⚠️  Failed to save %s output for its summary: %v\n", tool, err)
+		return result
+	}
+
+	tm.progressf("🧾 Summarizing %d bytes of %s output...\n", len(full), tool)
+	req := &common.ChatRequest{
+		Model: tm.model,
+		Messages: []common.Message{{
+			Role:    "user",
+			Content: fmt.Sprintf(summaryPrompt, tool, arguments, summaryInput(full)),
+		}},
+		Seed: tm.options.Seed,
+	}
+	resp, _, err := tm.chat(ctx, req)
+	var summary string
+	if err == nil && len(resp.Choices) > 0 {
+		// Reasoning is never wanted in a summary, whatever --strip-thinking says
+		summary, _ = stripThinking(resp.Choices[0].Message.Content, DefaultThinkingTags)
+		summary = strings.TrimSpace(summary)
+	}
+	if err == nil && summary == "" {
+		err = fmt.Errorf("the model returned no summary")
+	}
+	if err != nil {
+		slog.Warn("output summary failed", "tool", tool, "error", err)
+		tm.warnf("⚠️  Failed to summarize %s output, sending it as it is: %v\n", tool, err)
+		return result
+	}
+
+	slog.Info("tool output summarized", "tool", tool, "bytes", len(full), "summary_bytes", len(summary), "path", path)
+	result.fullOutput = full
+	result.Output = fmt.Sprintf("[summary of %d bytes of output; full output: %s]\n%s", len(full), path, summary)
+	return result
+}
+
+// summaryInput returns output as it is sent to be summarized: whole when it
+// fits summaryInputBytes, otherwise its start and end around a notice
+func summaryInput(output string) string {
+	if len(output) <= summaryInputBytes {
+		return output
+	}
+	half := summaryInputBytes / 2
+	head := truncateUTF8(output, half)
+	tail := output[len(output)-half:]
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+	return fmt.Sprintf("%s\n[... %d bytes left out ...]\n%s", head, len(output)-len(head)-len(tail), tail)
+}
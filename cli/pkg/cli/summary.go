@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// taskSummary collects the outcome of every tool a task ran so a short digest
+// can be printed when the task ends
+type taskSummary struct {
+	start    time.Time
+	tools    int
+	commands int // run_commands calls that ran, whether or not they succeeded
+	edits    int // edit_files calls that changed a file
+	denied   int
+	failed   int
+}
+
+func newTaskSummary() *taskSummary {
+	return &taskSummary{start: time.Now()}
+}
+
+// add records the result of one tool call
+func (s *taskSummary) add(tool string, result TaskResponse) {
+	s.tools++
+	switch result.Status {
+	case "denied":
+		s.denied++
+		return
+	case "cancelled":
+		return
+	case "error":
+		s.failed++
+	}
+	switch tool {
+	case "run_commands":
+		s.commands++
+	case "edit_files":
+		if result.Status == "success" {
+			s.edits++
+		}
+	}
+}
+
+// printSummary prints the task digest. Tasks that ran no tools only report
+// their token usage.
+func (tm *TaskManager) printSummary(s *taskSummary, total *usageTotal) {
+	usage := total.get()
+	if s.tools == 0 {
+		tm.printUsage(usage)
+		return
+	}
+
+	parts := []string{
+		fmt.Sprintf("%d command(s) run", s.commands),
+		fmt.Sprintf("%d file(s) edited", s.edits),
+	}
+	if s.denied > 0 {
+		parts = append(parts, fmt.Sprintf("%d denied", s.denied))
+	}
+	if s.failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", s.failed))
+	}
+	if usage.TotalTokens > 0 {
+		parts = append(parts, fmt.Sprintf("%d tokens", usage.TotalTokens))
+	}
+	elapsed := time.Since(s.start).Round(10 * time.Millisecond)
+	tm.progressf("📊 Summary: %s in %s\n", strings.Join(parts, ", "), elapsed)
+}
@@ -4,32 +4,210 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"golang.org/x/term"
+
 	"example.com/tinypenguin/pkg/common"
 )
 
 // TaskManager handles task execution with tinyllama integration
 type TaskManager struct {
-	tinyllamaClient *common.TinyllamaClient
+	tinyllamaClient common.LLMClient
 	model           string
 	toolsEnabled    bool
 	debugMode       bool
+	options         TaskOptions
+
+	// Shared with the managers of batch workers, see worker
+	commandSlots chan struct{}   // Held while a command runs, see TaskOptions.MaxParallelCommands
+	commandCache *commandCache   // Results of deterministic commands, see TaskOptions.CacheTTL
+	script       *scriptRecorder // Steps for --save-script; nil without it
+
+	// For --timestamps: when the task started and which tool call of the
+	// response is running (step 0 outside the tool loop). A manager runs one
+	// task at a time, so concurrent tasks each need their own, see worker.
+	started     time.Time
+	step, steps int
+}
+
+// TaskOptions holds optional settings for a task run. The zero value keeps
+// the default interactive behavior.
+type TaskOptions struct {
+	NoRate bool // Never prompt for a rating; tool calls are logged unrated
+	Rating int  // When > 0, assign this rating to every tool call instead of prompting
+
+	MaxTools int // Maximum tool executions per run; 0 means unlimited
+
+	Root string // When set, file tools may only touch paths inside this directory
+
+	CommandWrapper string // Optional wrapper prepended to every command, e.g. "firejail --quiet"
+
+	Shell string // Shell that runs commands with -c; empty picks bash, or sh when bash is missing
+
+	StdinFile string // File given to the standard input of commands that have no stdin argument
+
+	CacheTTL time.Duration // How long results of deterministic commands such as pwd or uname are reused; 0 disables the cache
+
+	SaveScript string // File the successful steps are written to as a bash script that repeats them
+
+	Verbose bool // Show high-level step information without the full debug dumps
+
+	Plan bool // Show the proposed tool calls and only execute them after confirmation
+
+	ModelFallback []string // Models tried in order when the primary model is not available
+
+	MaxOutputBytes int // Cap on tool output fed back to the model and log; 0 means unlimited
+
+	ContextTokens int // Estimated token budget for the conversation; oldest turns are trimmed beyond it
+
+	AuditLog string // Append-only file recording every command and its outcome, unredacted
+
+	Preflight bool // Check the endpoint serves the model before the first chat request
+
+	APIStyle string // common.APIStyleOpenAI (default) or common.APIStyleOllama
+
+	Images []common.Image // Images attached to the user query for vision models
+
+	AllowNetwork bool // Offer the http_fetch tool
+
+	RawOutput bool // Keep binary and control characters in command output instead of sanitizing it
+
+	Workdir string // Directory commands run in and relative paths resolve against; defaults to the process cwd
+
+	Quiet bool // Print only the final answer or command output; problems go to stderr
+
+	Timestamps bool // Prefix progress lines with the time since the task started and the tool call step
+
+	Yes bool // Apply file edits and package installs/removals without asking for confirmation
+
+	EnvPassthrough []string // When set, commands only see PATH and these variables (NAME or PREFIX*)
+	CleanEnv       bool     // Start commands from a minimal PATH instead of the inherited one
+
+	DenyPatterns  []string // Extra patterns that deny any command containing them
+	AllowPatterns []string // When set, commands must start with one of these prefixes
+
+	NoExec bool // Never execute anything; proposed tool calls and commands are only printed
+
+	DumpPrompt bool // Print the assembled system prompt and messages and stop before calling the model
+
+	ParallelTools int // Run up to this many read-only tool calls of one response at once; 0 or 1 runs all in order
+
+	MaxParallelCommands int // Most shell commands running at once, whatever runs them concurrently; 0 means no limit
+
+	ForceTools bool // Send the tools field even to models that appear not to support tool calling
+
+	PromptExamples bool // Add worked tool call examples to the system prompt
+
+	OmitToolList bool // Leave the tool list out of the system prompt and rely on the tools field alone
+
+	RatingContext bool // Repeat the call, its status and the ends of its output above the rating prompt
+
+	RetryToolCall bool // Ask once more, forcing a tool, when an action request gets a prose answer
+
+	MaxLogEntries int // Entries kept in tool_calls.log before the oldest are rotated out; 0 keeps all
+
+	OutputDir string // When set, each tool output is saved here in full and only its head is shown, logged and sent back
+
+	SummarizeOutput int // Tool outputs larger than this many bytes are replaced by a model-written summary; 0 disables it
+
+	AutoExec bool // Run safe-looking commands found in the answer text when the model makes no tool call
+
+	EditToolCalls bool // Show each tool call before it runs so the user can run, edit or skip it
+
+	// Safe asks before every command and refuses strictDenyPatterns as well;
+	// the CLI turns it on for interactive runs unless --unsafe or --yes is given
+	Safe bool
+
+	RunAs string // User run_commands runs as unless the model names one; switching users needs root
+
+	// AllowedUsers are the users the model may name in run_commands besides
+	// RunAs. Nil allows any user; an empty list allows none, so every
+	// command runs as RunAs (or the current user).
+	AllowedUsers []string
+
+	Seed *int // Sampling seed sent with the task's chat request; nil leaves it to the server
+
+	KeepAlive common.KeepAlive // How long Ollama keeps the model loaded after each request; native API and generate only
+
+	ThinkingTags []string // Reasoning blocks (<tag>...</tag>) removed from the answer before display and logging
+
+	CustomTools []CustomTool // Tools from the config file, offered alongside the built-ins
+
+	Session string // Name of a saved conversation to continue and update, see --session
+
+	Context []common.Message // Earlier messages from --context-file, sent before the query
+
+	ToolChoice string // auto, none, required or the name of a tool the model must call; empty leaves it to the model
+
+	MaxResponseBytes int64 // Largest model API response read; 0 means common.DefaultMaxResponseBytes
+
+	TaskTimeout time.Duration // Wall-clock budget for the whole task, model calls and commands included; 0 is unlimited
+
+	HTTPTrace io.Writer // When set, every model API request and response is written here in full
+
+	RecordFile string // Offline: append every chat request to this file instead of sending it
+	ReplayFile string // Offline: answer chat requests with the canned responses in this file
+
+	OnToolResult func(tool string, result TaskResponse) // Called after every tool execution, e.g. to stream results
 }
 
 // NewTaskManager creates a new task manager
-func NewTaskManager(tinyllamaURL, model string, toolsEnabled, debugMode bool) *TaskManager {
-	return &TaskManager{
-		tinyllamaClient: common.NewTinyllamaClient(tinyllamaURL),
+func NewTaskManager(tinyllamaURL, model string, toolsEnabled, debugMode bool, options TaskOptions) *TaskManager {
+	client := common.NewTinyllamaClient(tinyllamaURL)
+	if options.APIStyle != "" {
+		client.SetAPIStyle(options.APIStyle)
+	}
+	if options.MaxResponseBytes > 0 {
+		client.SetMaxResponseBytes(options.MaxResponseBytes)
+	}
+	if options.HTTPTrace != nil {
+		client.WithHTTPTrace(options.HTTPTrace)
+	}
+	tm := &TaskManager{
+		tinyllamaClient: client,
 		model:          model,
 		toolsEnabled:  toolsEnabled,
 		debugMode:     debugMode,
+		options:       options,
+		commandCache:  &commandCache{},
+	}
+	if options.MaxParallelCommands > 0 {
+		tm.commandSlots = make(chan struct{}, options.MaxParallelCommands)
+	}
+	if options.SaveScript != "" {
+		tm.script = &scriptRecorder{path: options.SaveScript, workdir: tm.workdir()}
+	}
+	return tm
+}
+
+// worker returns a manager for running a task alongside others, as batch
+// workers do. It has its own per-task state but shares the client, the
+// command slots, the command cache and the --save-script recorder, so those
+// limits and results span the whole batch.
+func (tm *TaskManager) worker() *TaskManager {
+	return &TaskManager{
+		tinyllamaClient: tm.tinyllamaClient,
+		model:           tm.model,
+		toolsEnabled:    tm.toolsEnabled,
+		debugMode:       tm.debugMode,
+		options:         tm.options,
+		commandSlots:    tm.commandSlots,
+		commandCache:    tm.commandCache,
+		script:          tm.script,
 	}
 }
 
@@ -43,6 +221,12 @@ type TaskResponse struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
 	Output  string `json:"output,omitempty"`
+
+	ExitCode  *int   `json:"exit_code,omitempty"`  // Exit status of a command that ran
+	ErrorKind string `json:"error_kind,omitempty"` // Class of a failed command, see classifyCommandError
+
+	fullOutput string // untruncated output for display when Output was capped
+	script     string // shell that repeats the call, for --save-script
 }
 
 // ToolCallLog represents a log entry for tool call usage with full conversation context
@@ -59,6 +243,12 @@ type ToolCallLog struct {
 	ErrorDetails     string    `json:"error_details,omitempty"`
 	ToolsEnabled     bool      `json:"tools_enabled"`
 	Rating           int       `json:"rating,omitempty"` // 1-5 stars for training data
+	RatingSource     string    `json:"rating_source,omitempty"` // manual, suggested (accepted default) or fixed (--rate)
+	ExitCode         *int      `json:"exit_code,omitempty"`
+	ErrorKind        string    `json:"error_kind,omitempty"`
+	Retry            bool      `json:"retry,omitempty"` // The tool call came from the retry after a prose answer
+	Edited           bool      `json:"edited,omitempty"` // The user changed the arguments before the call ran (--edit)
+	ProposedArguments string   `json:"proposed_arguments,omitempty"` // The model's arguments when Edited
 }
 
 // getLogPath returns the fixed path for the tool_calls.log file
@@ -96,48 +286,130 @@ func getLogPath() string {
 	return filepath.Join(wd, "tool_calls.log")
 }
 
+// logMu guards tool_calls.log against concurrent writers in this process
+var logMu sync.Mutex
+
+// readToolCallLogs reads all parseable entries from the log file
+func readToolCallLogs(logPath string) ([]ToolCallLog, error) {
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []ToolCallLog
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			var entry ToolCallLog
+			if json.Unmarshal([]byte(line), &entry) == nil {
+				logs = append(logs, entry)
+			}
+		}
+	}
+	return logs, nil
+}
+
+// writeToolCallLogs replaces the log file with the given entries. The data is
+// written to a temporary file first and renamed into place so an interrupted
+// write never leaves a truncated log behind.
+func writeToolCallLogs(logPath string, logs []ToolCallLog) error {
+	var logLines []string
+	for _, log := range logs {
+		if jsonBytes, err := json.Marshal(log); err == nil {
+			logLines = append(logLines, string(jsonBytes))
+		}
+	}
+
+	logContent := ""
+	if len(logLines) > 0 {
+		logContent = strings.Join(logLines, "\n") + "\n"
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(logPath), ".tool_calls.log.*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(logContent); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), logPath)
+}
+
+// DefaultMaxLogEntries is how many entries tool_calls.log keeps unless
+// --max-log-entries says otherwise
+const DefaultMaxLogEntries = 10000
+
 // logToolCall appends a tool call log entry to the tool_calls.log file
 // This function now stores full conversation context for fine-tuning
-func logToolCall(logEntry ToolCallLog) {
-	const maxEntries = 10000
+func (tm *TaskManager) logToolCall(logEntry ToolCallLog) {
 	logPath := getLogPath()
 
-	var existingLogs []ToolCallLog
+	// Serialize the read-modify-write when several tasks run concurrently
+	logMu.Lock()
+	defer logMu.Unlock()
 
 	// Read existing logs
-	if data, err := os.ReadFile(logPath); err == nil {
-		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
-		for _, line := range lines {
-			if strings.TrimSpace(line) != "" {
-				var entry ToolCallLog
-				if json.Unmarshal([]byte(line), &entry) == nil {
-					existingLogs = append(existingLogs, entry)
-				}
-			}
-		}
-	}
+	existingLogs, _ := readToolCallLogs(logPath)
 
 	// Add new entry
 	existingLogs = append(existingLogs, logEntry)
 
-	// Rotate if exceeded max entries
-	if len(existingLogs) > maxEntries {
-		existingLogs = existingLogs[len(existingLogs)-maxEntries:]
+	// Rotate if exceeded max entries; 0 leaves rotation to external tools
+	if tm.options.MaxLogEntries > 0 {
+		existingLogs = rotateLogs(existingLogs, tm.options.MaxLogEntries)
 	}
 
 	// Write back to file
-	var logLines []string
-	for _, log := range existingLogs {
-		if jsonBytes, err := json.Marshal(log); err == nil {
-			logLines = append(logLines, string(jsonBytes))
+	writeToolCallLogs(logPath, existingLogs)
+}
+
+// logCrashedToolCall is deferred by ExecuteTask. When the task panicked while
+// a tool call was in flight it logs that call as an error, since the normal
+// logging after execution never ran, and then resumes the panic.
+func (tm *TaskManager) logCrashedToolCall(pending *ToolCallLog, recovered interface{}) {
+	if recovered == nil {
+		return
+	}
+	if pending != nil {
+		pending.Status = "error"
+		pending.Message = "Task crashed while the tool was running"
+		pending.ErrorDetails = fmt.Sprintf("panic: %v", recovered)
+		tm.logToolCall(*pending)
+	}
+	panic(recovered)
+}
+
+func RunTask(query string, tinyllamaURL string, model string, toolsEnabled, debugMode bool, options TaskOptions) error {
+	manager, err := NewTaskManagerWithDefaults(tinyllamaURL, model, toolsEnabled, debugMode, options)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	if options.Preflight {
+		if err := manager.preflight(ctx); err != nil {
+			return err
 		}
 	}
 
-	logContent := strings.Join(logLines, "\n") + "\n"
-	os.WriteFile(logPath, []byte(logContent), 0644)
+	return manager.ExecuteTask(ctx, query)
 }
 
-func RunTask(query string, tinyllamaURL string, model string, toolsEnabled, debugMode bool) error {
+// NewTaskManagerWithDefaults applies environment defaults and validates the
+// options before creating a task manager
+func NewTaskManagerWithDefaults(tinyllamaURL string, model string, toolsEnabled, debugMode bool, options TaskOptions) (*TaskManager, error) {
 	if tinyllamaURL == "" {
 		// Check environment variable first
 		if envURL := os.Getenv("TINYLLAMA_URL"); envURL != "" {
@@ -154,164 +426,284 @@ func RunTask(query string, tinyllamaURL string, model string, toolsEnabled, debu
 			model = "qwen2.5-coder:3b"
 		}
 	}
-	manager := NewTaskManager(tinyllamaURL, model, toolsEnabled, debugMode)
-	return manager.ExecuteTask(context.Background(), query)
+	if options.Root != "" {
+		root, err := resolveRoot(options.Root)
+		if err != nil {
+			return nil, err
+		}
+		options.Root = root
+	}
+	if options.Workdir != "" {
+		workdir, err := filepath.Abs(options.Workdir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workdir %s: %w", options.Workdir, err)
+		}
+		options.Workdir = workdir
+	}
+	if options.CommandWrapper != "" {
+		if err := validateCommandWrapper(options.CommandWrapper); err != nil {
+			return nil, err
+		}
+	}
+	shell, err := resolveShell(options.Shell)
+	if err != nil {
+		return nil, err
+	}
+	options.Shell = shell
+	if options.StdinFile != "" {
+		stdinFile, err := filepath.Abs(options.StdinFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stdin file: %w", err)
+		}
+		if info, err := os.Stat(stdinFile); err != nil {
+			return nil, fmt.Errorf("invalid stdin file: %w", err)
+		} else if info.IsDir() {
+			return nil, fmt.Errorf("invalid stdin file: %s is a directory", stdinFile)
+		}
+		options.StdinFile = stdinFile
+	}
+	if options.OutputDir != "" {
+		outputDir, err := filepath.Abs(options.OutputDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output dir: %w", err)
+		}
+		options.OutputDir = outputDir
+	}
+	if options.AuditLog != "" {
+		auditLog, err := filepath.Abs(options.AuditLog)
+		if err != nil {
+			return nil, fmt.Errorf("invalid audit log path: %w", err)
+		}
+		options.AuditLog = auditLog
+	}
+	if _, err := resolveRunAs(options.RunAs); err != nil {
+		return nil, fmt.Errorf("invalid run-as user: %w", err)
+	}
+	manager := NewTaskManager(tinyllamaURL, model, toolsEnabled, debugMode, options)
+	switch options.ToolChoice {
+	case "", "auto", "none", "required":
+	default:
+		if _, ok := findTool(manager.availableTools(), options.ToolChoice); !ok {
+			return nil, fmt.Errorf("invalid tool choice %q: not auto, none, required or an available tool", options.ToolChoice)
+		}
+	}
+	if options.RecordFile != "" || options.ReplayFile != "" {
+		client, err := common.NewOfflineClient(options.RecordFile, options.ReplayFile)
+		if err != nil {
+			return nil, err
+		}
+		manager.tinyllamaClient = client
+	}
+	if options.Workdir != "" {
+		workdir, err := manager.resolveDir(options.Workdir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workdir: %w", err)
+		}
+		manager.options.Workdir = workdir
+	}
+	return manager, nil
+}
+
+// signalContext returns a context cancelled on Ctrl-C or SIGTERM so the
+// current tool call can still be logged. Once cancelled, default signal
+// handling is restored so a second Ctrl-C terminates immediately.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// stdinLines delivers lines read from stdin. A single background reader is
+// shared by all prompts so a prompt abandoned on cancellation does not
+// swallow input meant for the next one. It only reads while a prompt is
+// waiting, so a program run in between, such as $EDITOR, has the terminal
+// to itself.
+func stdinLines() <-chan string {
+	reader := stdinReader()
+	select {
+	case reader.wanted <- struct{}{}:
+	default:
+	}
+	return reader.lines
+}
+
+// lineReader is the background reader behind stdinLines
+type lineReader struct {
+	wanted chan struct{} // a prompt is waiting for a line
+	lines  chan string
+}
+
+var stdinReader = sync.OnceValue(func() *lineReader {
+	r := &lineReader{wanted: make(chan struct{}, 1), lines: make(chan string)}
+	go func() {
+		defer close(r.lines)
+		reader := bufio.NewReader(os.Stdin)
+		for range r.wanted {
+			line, err := reader.ReadString('\n')
+			if line != "" || err == nil {
+				r.lines <- line
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return r
+})
+
+// readLine reads a line from stdin, giving up when ctx is done or stdin is closed
+func readLine(ctx context.Context) (string, bool) {
+	select {
+	case line, ok := <-stdinLines():
+		return strings.TrimSpace(line), ok
+	case <-ctx.Done():
+		return "", false
+	}
 }
 
-// promptRating prompts the user to rate the tool usage (1-5 stars)
-func promptRating() int {
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("\n⭐ Rate this tool usage (1-5 stars, or 0 to skip): ")
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(input)
+// promptRating asks for a rating. When suggested is > 0 it is shown as the
+// default and pressing Enter accepts it; accepted reports whether that happened.
+func promptRating(ctx context.Context, suggested int) (rating int, accepted bool) {
+	if suggested > 0 {
+		fmt.Printf("\n⭐ Rate this tool usage (1-5 stars, or 0 to skip) [%d]: ", suggested)
+	} else {
+		fmt.Print("\n⭐ Rate this tool usage (1-5 stars, or 0 to skip): ")
+	}
+	input, ok := readLine(ctx)
+	if input == "" && ok && suggested > 0 {
+		return suggested, true
+	}
 	
 	rating, err := strconv.Atoi(input)
 	if err != nil || rating < 0 || rating > 5 {
-		return 0 // Skip rating if invalid
+		return 0, false // Skip rating if invalid
 	}
-	return rating
+	return rating, false
 }
 
-func (tm *TaskManager) ExecuteTask(ctx context.Context, query string) error {
-	fmt.Printf("🚀 Starting task: %s\n", query)
-	
-	// Create system prompt for RHCSA/bash operations
-	systemPrompt := `You are a Red Hat Certified System Administrator (RHCSA) assistant. 
-You help with Linux system administration tasks including:
-- File system operations (create, edit, delete files)
-- Package management (yum/dnf, rpm)
-- Service management (systemctl)
-- User and group management
-- Network configuration
-- Security (SELinux, firewall, permissions)
-
-CRITICAL INSTRUCTIONS FOR TOOL CALLING:
-When you need to execute a command or edit a file, you MUST use the tool_calls format in your response.
-DO NOT put JSON in your text content - the API expects tool_calls in a specific format.
-
-CORRECT FORMAT (what you MUST do):
-Your response should have a "tool_calls" array with this structure:
-{
-  "tool_calls": [
-    {
-      "id": "call_abc123",
-      "type": "function",
-      "function": {
-        "name": "run_commands",
-        "arguments": "{\"command\": \"who\"}"
-      }
-    }
-  ]
+// suggestedRating proposes a default rating from a tool result: clean
+// successes are usually good examples, failures and denials usually bad ones
+func suggestedRating(result TaskResponse) int {
+	switch result.Status {
+	case "success":
+		return 4
+	case "error", "denied":
+		return 1
+	default:
+		return 0
+	}
 }
 
-WRONG FORMAT (what you MUST NOT do):
-DO NOT put this in your content/text:
-{
-  "content": "` + "```json\\n{\\\"command\\\": \\\"who\\\"}\\n```" + `"
-}
+// confirmTimeout is how long confirm waits for an answer; 0 waits forever
+var confirmTimeout time.Duration
 
-DO NOT put this in your content/text:
-{
-  "content": "{\"name\": \"run_commands\", \"arguments\": {\"command\": \"who\"}}"
+// SetConfirmTimeout makes confirmation prompts answer no by themselves when
+// nobody replies within d, e.g. for --confirm-timeout
+func SetConfirmTimeout(d time.Duration) {
+	confirmTimeout = d
 }
 
-KEY RULES:
-1. ALWAYS use tool_calls array format (not JSON in content)
-2. The "arguments" field must be a JSON STRING (escaped), not an object
-3. For run_commands: arguments = "{\"command\": \"your-command-here\"}"
-4. For edit_files: arguments = "{\"path\": \"/path/to/file\", \"diff\": \"your-diff-here\"}"
-5. When user asks informational questions (like "check users"), ALWAYS use run_commands tool
-6. The tool name must be exactly "run_commands" or "edit_files" (as defined in available tools)
-
-EXAMPLES:
-
-User: "Check current users"
-You should respond with tool_calls containing:
-{
-  "tool_calls": [{
-    "id": "call_1",
-    "type": "function", 
-    "function": {
-      "name": "run_commands",
-      "arguments": "{\"command\": \"who\"}"
-    }
-  }]
+// confirm asks a yes/no question on stdin. Anything other than an explicit
+// yes, including cancellation, a closed stdin or no answer within the
+// confirmation timeout, counts as no.
+func confirm(ctx context.Context, question string) bool {
+	fmt.Printf("❓ %s [y/N]: ", question)
+
+	var expired <-chan time.Time
+	if confirmTimeout > 0 {
+		timer := time.NewTimer(confirmTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case line, ok := <-stdinLines():
+		if !ok {
+			fmt.Println()
+			return false
+		}
+		input := strings.ToLower(strings.TrimSpace(line))
+		return input == "y" || input == "yes"
+	case <-ctx.Done():
+		fmt.Println()
+		return false
+	case <-expired:
+		fmt.Println()
+		printWarning("⏱️  No answer within %s, assuming no\n", confirmTimeout)
+		slog.Warn("confirmation timed out", "question", question, "timeout", confirmTimeout)
+		return false
+	}
 }
 
-User: "What's the current directory?"
-You should respond with tool_calls containing:
-{
-  "tool_calls": [{
-    "id": "call_2",
-    "type": "function",
-    "function": {
-      "name": "run_commands", 
-      "arguments": "{\"command\": \"pwd\"}"
-    }
-  }]
+// rateToolCall returns the rating for a tool call and where it came from,
+// prompting only when rating is enabled and stdin is an interactive terminal
+func (tm *TaskManager) rateToolCall(ctx context.Context, tool, arguments string, result TaskResponse) (int, string) {
+	if tm.options.NoRate || ctx.Err() != nil {
+		return 0, ""
+	}
+	if tm.options.Rating > 0 {
+		return tm.options.Rating, "fixed"
+	}
+	if !isTerminal(os.Stdin) {
+		if tm.debugMode {
+			fmt.Printf("🐛 DEBUG - stdin is not a terminal, skipping rating prompt\n")
+		}
+		return 0, ""
+	}
+	if tm.options.RatingContext && !tm.options.Quiet {
+		printRatingContext(tool, arguments, result)
+	}
+	rating, accepted := promptRating(ctx, suggestedRating(result))
+	switch {
+	case rating == 0:
+		return 0, ""
+	case accepted:
+		return rating, "suggested"
+	default:
+		return rating, "manual"
+	}
 }
 
-Always prioritize security and provide safe, tested commands.
-Use sudo when necessary for administrative tasks.
+// isTerminal reports whether f is connected to a terminal
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
 
-Current working directory: ` + getCurrentDirectory() + `
-Available tools:
-- edit_files: Edit file contents using diff format
-- run_commands: Execute shell commands (USE THIS tool for ALL commands, including informational queries)`
+// ExecuteTask runs a task, within the --task-timeout budget when one is set
+func (tm *TaskManager) ExecuteTask(ctx context.Context, query string) error {
+	budget := tm.options.TaskTimeout
+	if budget <= 0 {
+		return tm.executeTask(ctx, query)
+	}
 
-	// Prepare messages for the model
-	messages := []common.Message{
-		{
-			Role:    "system",
-			Content: systemPrompt,
-		},
-		{
-			Role:    "user",
-			Content: query,
-		},
+	// The deadline covers every model call and tool execution; running
+	// commands are killed with it
+	ctx, cancel := context.WithTimeoutCause(ctx, budget, ErrTaskTimeout)
+	defer cancel()
+	err := tm.executeTask(ctx, query)
+	if err != nil && errors.Is(context.Cause(ctx), ErrTaskTimeout) {
+		printWarning("⏱️  Task budget of %s exceeded; stopped\n", budget)
+		slog.Warn("task budget exceeded", "query", query, "budget", budget, "error", err)
+		return fmt.Errorf("%w (%s)", ErrTaskTimeout, budget)
 	}
+	return err
+}
+
+// executeTask runs a task; ExecuteTask wraps it with the task budget
+func (tm *TaskManager) executeTask(ctx context.Context, query string) error {
+	tm.started, tm.step = time.Now(), 0
+	tm.progressf("🚀 Starting task: %s\n", query)
 
+	ctx, usage := withUsage(ctx)
+	summary := newTaskSummary()
+	defer tm.printSummary(summary, usage)
+	
 	// Define available tools (only if tools are enabled)
 	var tools []common.Tool
 	if tm.toolsEnabled {
-		tools = []common.Tool{
-			common.CreateToolDefinition(
-				"edit_files",
-				"Edit file contents by providing a diff of changes to make",
-				map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"path": map[string]interface{}{
-							"type":        "string",
-							"description": "Path to the file to edit",
-						},
-						"diff": map[string]interface{}{
-							"type":        "string",
-							"description": "Diff content showing changes to make",
-						},
-					},
-					"required": []interface{}{"path", "diff"},
-				},
-			),
-			common.CreateToolDefinition(
-				"run_commands",
-				"Execute shell commands on the system",
-				map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"command": map[string]interface{}{
-							"type":        "string",
-							"description": "Command to execute",
-						},
-						"timeout": map[string]interface{}{
-							"type":        "integer",
-							"description": "Timeout in seconds (optional)",
-						},
-					},
-					"required": []interface{}{"command"},
-				},
-			),
-		}
+		tools = tm.availableTools()
 		if tm.debugMode {
 			fmt.Printf("🔧 Tools enabled: %d tool(s) available\n", len(tools))
 			for _, tool := range tools {
@@ -320,9 +712,107 @@ Available tools:
 		}
 	} else {
 		if tm.debugMode {
-			fmt.Printf("⚠️  Tools are disabled - model will only provide text responses\n")
+			tm.progressf("⚠️  Tools are disabled - model will only provide text responses\n")
+		}
+	}
+
+	// Models without function calling ignore the tools field, so describe the
+	// call format in the prompt and read calls from the answer instead
+	toolsInText := false
+	if len(tools) > 0 && !tm.options.ForceTools {
+		if support, reason := tm.detectToolSupport(ctx); support == ToolSupportNo {
+			tm.warnf("⚠️  Model %s does not appear to support tool calling (%s); reading tool calls from its answer instead (--force-tools sends them anyway)\n", tm.model, reason)
+			slog.Warn("tools not sent", "model", tm.model, "reason", reason)
+			tools = nil
+			toolsInText = true
+		}
+	}
+
+	if len(tools) > 0 {
+		names := make([]string, len(tools))
+		for i, tool := range tools {
+			names[i] = tool.Function.Name
 		}
+		tm.verbosef("Tools offered: %s", strings.Join(names, ", "))
+	} else {
+		tm.verbosef("Tools offered: none")
+	}
+
+	// Create system prompt for RHCSA/bash operations
+	systemPrompt := personaPrompt(hostOS()) + "\n"
+	if !tm.options.NoExec {
+		// A model reading calls from text has no tools field to fall back on
+		listTools := !tm.options.OmitToolList || toolsInText
+		if !listTools {
+			tm.verbosef("Tool list left out of the system prompt")
+		} else if tm.options.OmitToolList {
+			tm.verbosef("Tool list kept in the system prompt: tools are not sent to this model")
+		}
+		systemPrompt += toolUsagePrompt(tm.availableTools(), toolsInText, tm.options.PromptExamples, listTools) + "\n"
+	}
+	systemPrompt += `Always prioritize security and provide safe, tested commands.
+Use sudo when necessary for administrative tasks.
+
+Current working directory: ` + tm.workdir() + `
+Operating system: ` + hostOS().String()
+	if shell := filepath.Base(tm.shell()); !tm.options.NoExec && shell != "bash" {
+		systemPrompt += "\nShell: commands run with " + shell + ", not bash; avoid bash-only syntax such as [[ ]], arrays and {a,b} expansion."
+	}
+	if !tm.options.NoExec && tm.options.StdinFile != "" {
+		systemPrompt += "\nInput: the user's file " + tm.options.StdinFile + " is the standard input of every command without a stdin argument, so e.g. `mysql db` loads it."
+	}
+	if tm.options.NoExec {
+		systemPrompt += `
+
+Nothing you suggest will be executed in this session. Answer in plain text: explain your reasoning and show any commands the user could run themselves.`
+	}
+
+	// Earlier turns of a saved session go between the system prompt and the query
+	var session *Session
+	if tm.options.Session != "" {
+		loaded, err := loadSession(tm.options.Session)
+		if err != nil {
+			return err
+		}
+		session = loaded
+		tm.verbosef("Session %s: %d earlier message(s)", session.Name, len(session.Messages))
+		defer func() {
+			if tm.options.DumpPrompt {
+				return // Nothing was sent, so the session has no new turn
+			}
+			if err := session.save(); err != nil {
+				tm.warnf("⚠️  Failed to save session %s: %v\n", session.Name, err)
+			}
+		}()
+	}
+	if tm.script != nil {
+		// Rewritten after every task, so a batch that stops early keeps its steps
+		defer func() {
+			if steps, err := tm.script.save(); err != nil {
+				tm.warnf("⚠️  Failed to save script %s: %v\n", tm.script.path, err)
+			} else if steps > 0 {
+				tm.progressf("📜 Saved %d step(s) to %s\n", steps, tm.script.path)
+			}
+		}()
+	}
+
+	// Prepare messages for the model
+	messages := []common.Message{
+		{
+			Role:    "system",
+			Content: systemPrompt,
+		},
+	}
+	if session != nil {
+		messages = append(messages, session.Messages...)
 	}
+	// --context-file turns follow; they are not saved to the session
+	messages = append(messages, tm.options.Context...)
+	messages = append(messages, common.Message{
+		Role:    "user",
+		Content: query,
+		Images:  tm.options.Images,
+	})
 
 	// Create chat request
 	chatReq := &common.ChatRequest{
@@ -330,30 +820,51 @@ Available tools:
 		Messages: messages,
 		Tools:    tools,
 		Stream:   false,
+		Seed:     tm.options.Seed,
+	}
+	if len(tools) > 0 {
+		chatReq.ToolChoice = common.NewToolChoice(tm.options.ToolChoice)
 	}
 	
+	if tm.options.DumpPrompt {
+		dumpPrompt(chatReq)
+		return nil
+	}
 	if tm.debugMode {
 		reqJSON, _ := json.MarshalIndent(chatReq, "", "  ")
 		fmt.Printf("🐛 DEBUG - Request:\n%s\n", string(reqJSON))
+		fmt.Printf("🐛 DEBUG - keep_alive: %s\n", tm.keepAliveSummary(tm.options.APIStyle == common.APIStyleOllama))
 	}
 
 	// Send request to the model
-	fmt.Printf("🤖 Analyzing task with %s...\n", tm.model)
+	tm.progressf("🤖 Analyzing task with %s...\n", tm.model)
 	if tm.debugMode {
 		fmt.Printf("🐛 DEBUG - Tools enabled: %v\n", tm.toolsEnabled)
+		fmt.Printf("🐛 DEBUG - Shell: %s -c\n", tm.shell())
 	}
 	
-	resp, err := tm.tinyllamaClient.Chat(ctx, chatReq)
+	slog.Info("chat request sent", "model", tm.model, "messages", len(messages), "tools", len(tools))
+	resp, model, err := tm.chat(ctx, chatReq)
 	if err != nil {
+		slog.Error("chat request failed", "model", tm.model, "error", err)
+		if hint := chatErrorHint(err, tm.model); hint != "" {
+			return fmt.Errorf("failed to get response from model: %w (%s)", err, hint)
+		}
 		return fmt.Errorf("failed to get response from model: %w", err)
 	}
 
 	if len(resp.Choices) == 0 {
+		slog.Error("chat response has no choices", "model", tm.model)
 		return fmt.Errorf("no response from model")
 	}
 
 	choice := resp.Choices[0]
 	message := choice.Message
+	slog.Info("chat response received", "model", resp.Model, "finish_reason", choice.FinishReason,
+		"tool_calls", len(message.ToolCalls), "prompt_tokens", resp.Usage.PromptTokens,
+		"completion_tokens", resp.Usage.CompletionTokens)
+	tm.verbosef("Response from %s: finish reason %q, %d tool call(s)", resp.Model, choice.FinishReason, len(message.ToolCalls))
+	tm.verbosef("Tokens: %d prompt + %d completion = %d", resp.Usage.PromptTokens, resp.Usage.CompletionTokens, resp.Usage.TotalTokens)
 	
 	if tm.debugMode {
 		respJSON, _ := json.MarshalIndent(resp, "", "  ")
@@ -368,9 +879,21 @@ Available tools:
 		}
 	}
 	
+	if len(tm.options.ThinkingTags) > 0 && message.Content != "" {
+		content, thoughts := stripThinking(message.Content, tm.options.ThinkingTags)
+		if len(thoughts) > 0 {
+			tm.verbosef("Stripped %d reasoning block(s) from the answer", len(thoughts))
+			if tm.debugMode {
+				fmt.Printf("🐛 DEBUG - Stripped reasoning:\n%s\n", strings.Join(thoughts, "\n---\n"))
+			}
+		}
+		message.Content = content
+	}
+
 	// Try to extract tool calls from content if they're not in proper format
-	// This handles cases where models return tool calls as JSON in content field
-	if len(message.ToolCalls) == 0 && message.Content != "" {
+	// This handles cases where models return tool calls as JSON in content field.
+	// In no-exec mode the content is the answer and is left as it is.
+	if len(message.ToolCalls) == 0 && message.Content != "" && !tm.options.NoExec {
 		if tm.debugMode {
 			fmt.Printf("🐛 DEBUG - Attempting to extract tool calls from content\n")
 		}
@@ -384,47 +907,135 @@ Available tools:
 			fmt.Printf("🐛 DEBUG - No tool calls extracted from content\n")
 		}
 	}
+
+	// A prose answer to an action request gets one more chance, with a
+	// stricter instruction and a tool forced
+	retried := false
+	if tm.options.RetryToolCall && len(message.ToolCalls) == 0 && len(tools) > 0 && !tm.options.NoExec &&
+		tm.options.ToolChoice != "none" && needsExecution(query) {
+		if command, _ := tm.parseCommandFromResponse(message.Content); command == "" {
+			if reply, servedBy, ok := tm.retryForToolCall(ctx, chatReq, message); ok {
+				message, model, retried = reply, servedBy, true
+			}
+		}
+	}
+
+	session.add(messages[len(messages)-1], message)
+
+	if tm.options.Plan && !tm.showPlan(ctx, message) {
+		return nil
+	}
+	if tm.options.NoExec && len(message.ToolCalls) > 0 {
+		tm.progressf("💡 The model proposes %d tool call(s); nothing was executed\n", len(message.ToolCalls))
+		if !tm.options.Quiet {
+			printToolCalls(message.ToolCalls)
+		}
+		if message.Content != "" {
+			fmt.Println(message.Content)
+		}
+		return nil
+	}
 	
 	// Serialize model response for logging
 	modelResponseJSON, _ := json.Marshal(message)
 	modelResponseStr := string(modelResponseJSON)
 
+	// Set when any executed tool fails or is denied, so the exit code reflects it
+	toolFailed := false
+
+	// The entry of the tool call being executed; it is still logged if the
+	// task panics before the call finishes
+	var pending *ToolCallLog
+	defer func() { tm.logCrashedToolCall(pending, recover()) }()
+
 	// Check if the model wants to use tools
 	if len(message.ToolCalls) > 0 {
-		fmt.Printf("🔧 Model wants to use %d tool(s)\n", len(message.ToolCalls))
-		
-		for _, toolCall := range message.ToolCalls {
-			fmt.Printf("🛠️  Executing tool: %s\n", toolCall.Function.Name)
+		tm.progressf("🔧 Model wants to use %d tool(s)\n", len(message.ToolCalls))
+
+		parallel := tm.options.ParallelTools > 1 && !tm.options.EditToolCalls && !tm.options.Safe
+		prefetched := make(map[int]parallelResult)
+
+		for i, toolCall := range message.ToolCalls {
+			if ctx.Err() != nil {
+				tm.warnf("🛑 Task cancelled, skipped %d remaining tool call(s)\n", len(message.ToolCalls)-i)
+				break
+			}
+			if tm.options.MaxTools > 0 && i >= tm.options.MaxTools {
+				skipped := len(message.ToolCalls) - i
+				tm.warnf("🛑 Tool limit of %d reached, skipped %d remaining tool call(s)\n", tm.options.MaxTools, skipped)
+				break
+			}
+			// Read-only calls from here up to the next other call run at
+			// once; every call before them has finished by now
+			if _, ok := prefetched[i]; !ok && parallel {
+				maps.Copy(prefetched, tm.runReadOnlyInParallel(ctx, message.ToolCalls, i, tm.options.MaxTools, tm.options.ParallelTools))
+			}
+
+			tm.step, tm.steps = i+1, len(message.ToolCalls)
+			tm.progressf("🛠️  Executing tool: %s\n", toolCall.Function.Name)
+			slog.Info("tool dispatched", "tool", toolCall.Function.Name, "id", toolCall.ID)
+			tm.verbosef("Tool call %d/%d: %s %s", i+1, len(message.ToolCalls), toolCall.Function.Name, toolCall.Function.Arguments)
 
+			pending = &ToolCallLog{
+				Timestamp:     time.Now(),
+				Model:         model,
+				UserQuery:     query,
+				ModelResponse: modelResponseStr,
+				ToolName:      toolCall.Function.Name,
+				Arguments:     toolCall.Function.Arguments,
+				ToolsEnabled:  tm.toolsEnabled,
+				Retry:         retried,
+			}
 			var toolResult TaskResponse
+			proposed := ""
 
-			switch toolCall.Function.Name {
-			case "edit_files":
-				toolResult = tm.executeEditFiles(toolCall.Function.Arguments)
-			case "run_commands":
-				toolResult = tm.executeRunCommands(toolCall.Function.Arguments)
-			default:
+			if done, ok := prefetched[i]; ok {
+				toolCall.Function.Arguments = done.arguments
+				toolResult = done.result
+			} else if args, err := tm.repairArguments(ctx, model, messages, tools, message, toolCall); err != nil {
 				toolResult = TaskResponse{
 					Status:  "error",
-					Message: fmt.Sprintf("Unknown tool: %s", toolCall.Function.Name),
+					Message: fmt.Sprintf("Invalid %s arguments: %v", toolCall.Function.Name, err),
+				}
+			} else if toolCall.Function.Arguments, err = tm.reviewToolCall(ctx, toolCall.Function.Name, args); err != nil {
+				toolCall.Function.Arguments = args
+				toolResult = TaskResponse{Status: "denied", Message: err.Error()}
+			} else {
+				if toolCall.Function.Arguments != args {
+					proposed = args
+					pending.Arguments, pending.Edited, pending.ProposedArguments = toolCall.Function.Arguments, true, args
+				}
+				toolResult = tm.validateToolCall(toolCall)
+				if toolResult.Status == "" {
+					toolResult = tm.dispatchTool(ctx, toolCall)
 				}
 			}
 
-			fmt.Printf("📊 Tool result: %s - %s\n", toolResult.Status, toolResult.Message)
-			if toolResult.Output != "" {
-				fmt.Printf("📤 Output:\n%s\n", toolResult.Output)
+			if toolResult.Status == "error" || toolResult.Status == "denied" {
+				toolFailed = true
+			}
+			tm.printResult(toolResult)
+			logToolResult(toolCall.Function.Name, toolResult)
+			summary.add(toolCall.Function.Name, toolResult)
+			session.add(toolResultMessage(toolCall, toolResult))
+			tm.script.add(query, toolCall.Function.Name, i+1, toolResult)
+			if tm.options.OnToolResult != nil {
+				tm.options.OnToolResult(toolCall.Function.Name, toolResult)
+			}
+			if toolResult.Status == "denied" || toolResult.ErrorKind != "" {
+				tm.suggestAfterFailure(ctx, model, messages, tools, message, toolCall, toolResult)
 			}
 
 			// Prompt for rating
-			rating := promptRating()
+			rating, ratingSource := tm.rateToolCall(ctx, toolCall.Function.Name, toolCall.Function.Arguments, toolResult)
 			if rating > 0 {
-				fmt.Printf("⭐ Rating saved: %d/5 stars\n", rating)
+				tm.progressf("⭐ Rating saved: %d/5 stars\n", rating)
 			}
 
 			// Log the tool call for training with full conversation context
 			logEntry := ToolCallLog{
 				Timestamp:     time.Now(),
-				Model:         tm.model,
+				Model:         model,
 				UserQuery:     query, // Store original user query
 				ModelResponse: modelResponseStr, // Store full model response
 				ToolName:      toolCall.Function.Name,
@@ -434,6 +1045,12 @@ Available tools:
 				Output:        toolResult.Output,
 				ToolsEnabled:  tm.toolsEnabled,
 				Rating:        rating,
+				RatingSource:  ratingSource,
+				ExitCode:      toolResult.ExitCode,
+				ErrorKind:     toolResult.ErrorKind,
+				Retry:         retried,
+				Edited:        proposed != "",
+				ProposedArguments: proposed,
 				ErrorDetails: func() string {
 					if toolResult.Status == "error" {
 						return toolResult.Message
@@ -441,7 +1058,12 @@ Available tools:
 					return ""
 				}(),
 			}
-			logToolCall(logEntry)
+			tm.logToolCall(logEntry)
+			pending = nil
+		}
+		tm.step = 0
+		if ctx.Err() != nil {
+			return ErrCancelled
 		}
 	} else {
 		if tm.debugMode {
@@ -450,35 +1072,67 @@ Available tools:
 		
 		// Try to parse JSON response that might contain command suggestions
 		// This handles cases where the model returns malformed tool calls in content
-		command, shouldExecute := tm.parseCommandFromResponse(message.Content)
+		var command string
+		shouldExecute := false
+		if !tm.options.NoExec {
+			command, shouldExecute = tm.parseCommandFromResponse(message.Content)
+		}
 		
 		if tm.debugMode {
 			fmt.Printf("🐛 DEBUG - Parsed command: '%s', shouldExecute: %v\n", command, shouldExecute)
 		}
 		
-		if shouldExecute && command != "" {
+		if shouldExecute && command != "" && tm.options.AutoExec {
 			// For informational questions, automatically execute the suggested command
-			fmt.Printf("💡 Detected command suggestion in response: %s\n", command)
-			fmt.Printf("⚠️  Note: Model should use tool_calls format, but detected command in content. Executing anyway...\n")
-			fmt.Printf("🚀 Executing command to answer your question...\n\n")
+			tm.progressf("💡 Detected command suggestion in response: %s\n", command)
+			if !tm.options.Quiet {
+				printWarning("⚠️  Note: Model should use tool_calls format, but detected command in content. Executing anyway...\n")
+			}
+			tm.progressf("🚀 Executing command to answer your question...\n\n")
 			
 			// Properly escape the command in JSON
 			cmdJSON, _ := json.Marshal(map[string]string{"command": command})
-			toolResult := tm.executeRunCommands(string(cmdJSON))
+			slog.Info("tool dispatched from content", "tool", "run_commands", "command", command)
+			pending = &ToolCallLog{
+				Timestamp:     time.Now(),
+				Model:         model,
+				UserQuery:     query,
+				ModelResponse: modelResponseStr,
+				ToolName:      "run_commands",
+				Arguments:     string(cmdJSON),
+				ToolsEnabled:  tm.toolsEnabled,
+			}
+			if !readOnlyToolCall("run_commands", string(cmdJSON)) {
+				tm.commandCache.clear()
+			}
+			toolResult := tm.writeOutputFile("run_commands", tm.executeRunCommands(ctx, string(cmdJSON)))
+			toolResult = tm.summarizeOutput(ctx, "run_commands", string(cmdJSON), toolResult)
+			logToolResult("run_commands", toolResult)
+			summary.add("run_commands", toolResult)
+			tm.script.add(query, "run_commands", 1, toolResult)
+			if tm.options.OnToolResult != nil {
+				tm.options.OnToolResult("run_commands", toolResult)
+			}
+			if toolResult.Status == "error" || toolResult.Status == "denied" {
+				toolFailed = true
+			}
 			
-			if toolResult.Status == "success" {
-				fmt.Printf("✅ Answer:\n%s\n", toolResult.Output)
+			if tm.options.Quiet {
+				tm.printResult(toolResult)
+			} else if toolResult.Status == "success" {
+				printSuccess("✅ Answer:\n")
+				fmt.Printf("%s\n", toolResult.displayOutput())
 			} else {
-				fmt.Printf("❌ Error executing command: %s\n", toolResult.Message)
+				printError("❌ Error executing command: %s\n", toolResult.Message)
 				if toolResult.Output != "" {
-					fmt.Printf("Output: %s\n", toolResult.Output)
+					fmt.Printf("Output: %s\n", toolResult.displayOutput())
 				}
 			}
 
 			// Prompt for rating
-			rating := promptRating()
+			rating, ratingSource := tm.rateToolCall(ctx, "run_commands", string(cmdJSON), toolResult)
 			if rating > 0 {
-				fmt.Printf("⭐ Rating saved: %d/5 stars\n", rating)
+				tm.progressf("⭐ Rating saved: %d/5 stars\n", rating)
 			}
 
 			// Log the tool call for training (fallback path - malformed tool call)
@@ -488,7 +1142,7 @@ Available tools:
 			
 			logEntry := ToolCallLog{
 				Timestamp:     time.Now(),
-				Model:         tm.model,
+				Model:         model,
 				UserQuery:     query, // Store original user query
 				ModelResponse: fallbackModelResponseStr, // Store full model response
 				ToolName:      "run_commands",
@@ -498,6 +1152,9 @@ Available tools:
 				Output:        toolResult.Output,
 				ToolsEnabled:  tm.toolsEnabled,
 				Rating:        rating,
+				RatingSource:  ratingSource,
+				ExitCode:      toolResult.ExitCode,
+				ErrorKind:     toolResult.ErrorKind,
 				ErrorDetails: func() string {
 					if toolResult.Status == "error" {
 						return toolResult.Message
@@ -505,13 +1162,23 @@ Available tools:
 					return ""
 				}(),
 			}
-			logToolCall(logEntry)
+			tm.logToolCall(logEntry)
+			pending = nil
+			if ctx.Err() != nil {
+				return ErrCancelled
+			}
+		} else if command != "" && tm.options.Quiet {
+			fmt.Println(command)
 		} else if command != "" {
-			// Command found but not safe to auto-execute
+			// Command found but auto-exec is off or it is not safe to run
 			fmt.Printf("💡 Model suggested command: %s\n", command)
-			fmt.Printf("⚠️  Note: Model should use tool_calls format instead of JSON in content.\n")
-			fmt.Printf("💬 Suggested command: %s\n", command)
+			printWarning("⚠️  Note: Model should use tool_calls format instead of JSON in content.\n")
 			fmt.Printf("💬 To execute this command, you can run: %s\n", command)
+			if shouldExecute {
+				fmt.Println("💬 Pass --auto-exec to run suggested read-only commands automatically")
+			}
+		} else if message.Content != "" && tm.options.Quiet {
+			fmt.Println(message.Content)
 		} else if message.Content != "" {
 			// Display the model's response if it's not just JSON
 			// Check if it's valid JSON - if so, try to extract useful info
@@ -527,51 +1194,157 @@ Available tools:
 			} else {
 				// Not JSON, display as-is
 				fmt.Printf("💬 Answer:\n%s\n", message.Content)
+				if !tm.toolsEnabled || tm.options.NoExec {
+					printSuggestedCommands(message.Content)
+				}
 			}
 		} else {
-			fmt.Println("✅ Task completed without tool usage")
+			tm.warnf("⚠️  Model returned an empty response\n")
+			return ErrNoAction
 		}
 	}
 
+	if toolFailed {
+		return ErrToolFailed
+	}
 	return nil
 }
 
-func (tm *TaskManager) executeEditFiles(arguments string) TaskResponse {
-	var params struct {
-		Path string `json:"path"`
-		Diff string `json:"diff"`
+// builtinTools returns the definitions of the tools offered to the model
+func builtinTools() []common.Tool {
+	return []common.Tool{
+		common.CreateToolDefinition(
+			"edit_files",
+			"Edit file contents by providing a unified diff, or SEARCH/REPLACE blocks, of changes to make",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the file to edit",
+					},
+					"diff": map[string]interface{}{
+						"type":        "string",
+						"description": "Unified diff (@@ hunks) showing changes to make, or one or more blocks of the form \"<<<<<<< SEARCH\\n<exact lines to find>\\n=======\\n<replacement lines>\\n>>>>>>> REPLACE\"",
+					},
+				},
+				"required": []interface{}{"path", "diff"},
+			},
+		),
+		common.CreateToolDefinition(
+			"run_commands",
+			"Execute shell commands on the system",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"command": map[string]interface{}{
+						"type":        "string",
+						"description": "Command to execute",
+					},
+					"timeout": map[string]interface{}{
+						"type":        "integer",
+						"description": "Timeout in seconds (optional)",
+					},
+					"cwd": map[string]interface{}{
+						"type":        "string",
+						"description": "Directory to run the command in (optional, defaults to the working directory)",
+					},
+					"user": map[string]interface{}{
+						"type":        "string",
+						"description": "User to run the command as, e.g. a service account (optional)",
+					},
+					"stdin": map[string]interface{}{
+						"type":        "string",
+						"description": "Text written to the command's standard input, then closed, e.g. SQL for mysql or answers to prompts (optional)",
+					},
+				},
+				"required": []interface{}{"command"},
+			},
+		),
 	}
-	
-	if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+}
+
+// availableTools returns the built-in tools plus any enabled by options
+func (tm *TaskManager) availableTools() []common.Tool {
+	tools := append(builtinTools(), managePackageTool())
+	if tm.options.AllowNetwork {
+		tools = append(tools, httpFetchTool())
+	}
+	for _, tool := range tm.options.CustomTools {
+		tools = append(tools, tool.definition())
+	}
+	return tools
+}
+
+// findTool returns the definition of the named tool
+func findTool(tools []common.Tool, name string) (common.Tool, bool) {
+	for _, tool := range tools {
+		if tool.Function.Name == name {
+			return tool, true
+		}
+	}
+	return common.Tool{}, false
+}
+
+// validateToolCall checks the arguments of a tool call against the tool's
+// declared parameters. It returns an error response listing the violations,
+// or a zero TaskResponse when the call is valid.
+func (tm *TaskManager) validateToolCall(toolCall common.ToolCall) TaskResponse {
+	tool, ok := findTool(tm.availableTools(), toolCall.Function.Name)
+	if !ok {
 		return TaskResponse{
 			Status:  "error",
-			Message: fmt.Sprintf("Failed to parse edit_files arguments: %v", err),
+			Message: fmt.Sprintf("Unknown tool: %s", toolCall.Function.Name),
 		}
 	}
-
-	fmt.Printf("📝 Editing file: %s\n", params.Path)
-	fmt.Printf("📝 Diff:\n%s\n", params.Diff)
-	
-	// For now, just validate the input and return success
-	// In a real implementation, you would apply the diff to the file
-	if params.Path == "" || params.Diff == "" {
+	if violations := validateArguments(tool.Function.Parameters, toolCall.Function.Arguments); len(violations) > 0 {
 		return TaskResponse{
 			Status:  "error",
-			Message: "Both path and diff are required",
+			Message: fmt.Sprintf("Invalid %s arguments: %s", toolCall.Function.Name, strings.Join(violations, "; ")),
 		}
 	}
-	
-	return TaskResponse{
-		Status:  "success",
-		Message: fmt.Sprintf("File edit operation would be applied to %s", params.Path),
-		Output:  fmt.Sprintf("Applied diff to %s", params.Path),
+	return TaskResponse{}
+}
+
+// dispatchTool routes a tool call to its implementation
+func (tm *TaskManager) dispatchTool(ctx context.Context, toolCall common.ToolCall) (result TaskResponse) {
+	if !readOnlyToolCall(toolCall.Function.Name, toolCall.Function.Arguments) {
+		tm.commandCache.clear() // The call may change what cached commands print
+	}
+	defer func() {
+		result = tm.writeOutputFile(toolCall.Function.Name, result)
+		result = tm.summarizeOutput(ctx, toolCall.Function.Name, toolCall.Function.Arguments, result)
+	}()
+
+	switch toolCall.Function.Name {
+	case "edit_files":
+		return tm.executeEditFiles(ctx, toolCall.Function.Arguments)
+	case "run_commands":
+		return tm.executeRunCommands(ctx, toolCall.Function.Arguments)
+	case "manage_package":
+		return tm.executeManagePackage(ctx, toolCall.Function.Arguments)
+	case "http_fetch":
+		return tm.executeHTTPFetch(ctx, toolCall.Function.Arguments)
+	default:
+		if tool, ok := tm.findCustomTool(toolCall.Function.Name); ok {
+			return tm.executeCustomTool(ctx, tool, toolCall.Function.Arguments)
+		}
+		return TaskResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("Unknown tool: %s", toolCall.Function.Name),
+		}
 	}
 }
 
-func (tm *TaskManager) executeRunCommands(arguments string) TaskResponse {
+func (tm *TaskManager) executeRunCommands(parent context.Context, arguments string) (result TaskResponse) {
+	defer func() { result = tm.capOutput(result) }()
+
 	var params struct {
 		Command string `json:"command"`
 		Timeout *int   `json:"timeout,omitempty"`
+		Cwd     string `json:"cwd,omitempty"`
+		User    string `json:"user,omitempty"`
+		Stdin   *string `json:"stdin,omitempty"`
 	}
 	
 	if err := json.Unmarshal([]byte(arguments), &params); err != nil {
@@ -581,7 +1354,41 @@ func (tm *TaskManager) executeRunCommands(arguments string) TaskResponse {
 		}
 	}
 
-	fmt.Printf("💻 Executing command: %s\n", params.Command)
+	dir := tm.workdir()
+	if params.Cwd != "" {
+		resolved, err := tm.resolveDir(params.Cwd)
+		if err != nil {
+			return TaskResponse{
+				Status:  "denied",
+				Message: fmt.Sprintf("Working directory was denied: %v", err),
+			}
+		}
+		dir = resolved
+	}
+
+	if params.User != "" && params.User != tm.options.RunAs && tm.options.AllowedUsers != nil && !slices.Contains(tm.options.AllowedUsers, params.User) {
+		slog.Warn("command denied", "command", params.Command, "user", params.User, "reason", "user not allowed")
+		tm.audit(params.Command, dir, "denied", nil, "denied")
+		return TaskResponse{
+			Status:  "denied",
+			Message: fmt.Sprintf("Running commands as %s is not allowed here; leave user out to run as the default user", params.User),
+		}
+	}
+	if params.User == "" {
+		params.User = tm.options.RunAs
+	}
+	input := ""
+	switch {
+	case params.Stdin != nil:
+		input = fmt.Sprintf(" (%d bytes on stdin)", len(*params.Stdin))
+	case tm.options.StdinFile != "":
+		input = fmt.Sprintf(" (stdin from %s)", tm.options.StdinFile)
+	}
+	if params.User != "" {
+		tm.progressf("💻 Executing command in %s as %s: %s%s\n", dir, params.User, params.Command, input)
+	} else {
+		tm.progressf("💻 Executing command in %s: %s%s\n", dir, params.Command, input)
+	}
 	
 	// Validate command
 	if params.Command == "" {
@@ -592,50 +1399,183 @@ func (tm *TaskManager) executeRunCommands(arguments string) TaskResponse {
 	}
 
 	// Check for dangerous commands
-	if isDangerousCommand(params.Command) {
+	if reason := tm.deniedReason(params.Command); reason != "" {
+		slog.Warn("command denied", "command", params.Command, "reason", reason)
+		tm.audit(params.Command, dir, "denied", nil, "denied")
 		return TaskResponse{
 			Status:  "denied",
-			Message: "Command was denied for safety reasons",
+			Message: fmt.Sprintf("Command was denied for safety reasons: %s", reason),
+		}
+	}
+	runAs, err := resolveRunAs(params.User)
+	if err != nil {
+		slog.Warn("command denied", "command", params.Command, "user", params.User, "reason", err)
+		tm.audit(params.Command, dir, "denied", nil, "denied")
+		return TaskResponse{
+			Status:  "denied",
+			Message: fmt.Sprintf("Cannot run the command as %s: %v", params.User, err),
+		}
+	}
+
+	// Commands that always print the same may be answered from the cache;
+	// nothing runs, so there is nothing to confirm
+	cacheKey := commandCacheKey(params.Command, dir, params.User)
+	cacheable := tm.options.CacheTTL > 0 && params.Stdin == nil && cacheableCommand(params.Command)
+	if cacheable {
+		if cached, age, ok := tm.commandCache.lookup(cacheKey, tm.options.CacheTTL); ok {
+			tm.audit(params.Command, dir, "cached", cached.ExitCode, cached.Status)
+			cached.Message += fmt.Sprintf(" (cached result from %s ago)", age.Round(100*time.Millisecond))
+			return cached
+		}
+	}
+
+	// Safe mode asks before every command; a confirmed plan or an edit
+	// review already did
+	decision := tm.commandDecision()
+	if tm.options.Safe && !tm.options.Plan && !tm.options.Yes && !(tm.options.EditToolCalls && isTerminal(os.Stdin)) {
+		if !confirm(parent, fmt.Sprintf("Run `%s`%s?", params.Command, input)) {
+			tm.audit(params.Command, dir, "denied", nil, "denied")
+			return TaskResponse{
+				Status:  "denied",
+				Message: "Command was not confirmed",
+			}
+		}
+		decision = "confirmed"
+	}
+
+	// The command reads the model's stdin text, or the --stdin-file, or
+	// nothing, so commands that read until EOF always end
+	var stdin io.Reader
+	if params.Stdin != nil {
+		stdin = strings.NewReader(*params.Stdin)
+	} else if tm.options.StdinFile != "" {
+		file, err := os.Open(tm.options.StdinFile)
+		if err != nil {
+			return TaskResponse{
+				Status:  "error",
+				Message: fmt.Sprintf("Failed to open the stdin file: %v", err),
+			}
 		}
+		defer file.Close()
+		stdin = file
 	}
 
 	// Execute the command
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	timeout := 30 * time.Second
 	if params.Timeout != nil {
-		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(*params.Timeout)*time.Second)
+		timeout = time.Duration(*params.Timeout) * time.Second
+	}
+	result = tm.runCommand(parent, params.Command, dir, timeout, decision, runAs, stdin)
+	scriptDir := "" // The script starts in the working directory already
+	if params.Cwd != "" {
+		scriptDir = dir
 	}
+	result.script = tm.commandScript(params.Command, scriptDir, params.User, params.Stdin)
+	if cacheable {
+		tm.commandCache.store(cacheKey, result)
+	}
+	return result
+}
+
+// runCommand executes an already validated command in dir, as runAs when it
+// is not nil, with stdin as its input (none when nil), and records it in the
+// audit log with the given approval decision
+func (tm *TaskManager) runCommand(parent context.Context, command, dir string, timeout time.Duration, decision string, runAs *runAsUser, stdin io.Reader) (result TaskResponse) {
+	// The timeout starts once the command may run, not while it waits its turn
+	release, err := tm.acquireCommandSlot(parent)
+	if err != nil {
+		tm.audit(command, dir, decision, nil, "cancelled")
+		return TaskResponse{
+			Status:  "cancelled",
+			Message: "Command was cancelled before it started",
+		}
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "bash", "-c", params.Command)
+	cmd := tm.buildCommand(ctx, command)
+	runAs.apply(cmd)
 	
-	// Set working directory
-	wd, _ := os.Getwd()
-	cmd.Dir = wd
+	cmd.Dir = dir
+	cmd.Stdin = stdin
 	
-	output, err := cmd.CombinedOutput()
+	rawOutput, err := cmd.CombinedOutput()
+	output := tm.sanitizeOutput(rawOutput)
+	defer func() {
+		var exitCode *int
+		if cmd.ProcessState != nil {
+			code := cmd.ProcessState.ExitCode()
+			exitCode = &code
+		}
+		tm.audit(command, dir, decision, exitCode, result.Status)
+	}()
 	
 	if err != nil {
+		if parent.Err() != nil {
+			return TaskResponse{
+				Status:  "cancelled",
+				Message: "Command was cancelled",
+				Output:  output,
+			}
+		}
 		if ctx.Err() == context.DeadlineExceeded {
 			return TaskResponse{
-				Status:  "error",
-				Message: "Command timed out",
+				Status:    "error",
+				Message:   fmt.Sprintf("Command timed out after %s", timeout),
+				ErrorKind: ErrorKindTimeout,
 			}
 		}
+		exitCode, kind := classifyCommandError(err, string(output))
 		return TaskResponse{
-			Status:  "error",
-			Message: fmt.Sprintf("Command failed: %v", err),
-			Output:  string(output),
+			Status:    "error",
+			Message:   commandFailureMessage(exitCode, kind),
+			Output:    output,
+			ExitCode:  exitCode,
+			ErrorKind: kind,
 		}
 	}
 	
+	exitCode := 0
 	return TaskResponse{
-		Status:  "success",
-		Message: "Command executed successfully",
-		Output:  string(output),
+		Status:   "success",
+		Message:  "Command executed successfully",
+		Output:   output,
+		ExitCode: &exitCode,
+	}
+}
+
+// verbosef prints a step-level trace line in verbose mode. Debug mode already
+// prints the same information as part of its full dumps, so it is skipped there.
+func (tm *TaskManager) verbosef(format string, args ...interface{}) {
+	if tm.options.Verbose && !tm.debugMode && !tm.options.Quiet {
+		fmt.Printf(tm.stamp()+"🔎 %s\n", fmt.Sprintf(format, args...))
+	}
+}
+
+// logToolResult records the outcome of a tool execution in the operational log
+func logToolResult(tool string, result TaskResponse) {
+	switch result.Status {
+	case "success":
+		slog.Info("tool finished", "tool", tool, "status", result.Status)
+	case "denied", "cancelled":
+		slog.Warn("tool "+result.Status, "tool", tool, "message", result.Message)
+	default:
+		attrs := []any{"tool", tool, "status", result.Status, "message", result.Message}
+		if result.ErrorKind != "" {
+			attrs = append(attrs, "kind", result.ErrorKind)
+		}
+		if result.ExitCode != nil {
+			attrs = append(attrs, "exit_code", *result.ExitCode)
+		}
+		slog.Error("tool failed", attrs...)
 	}
 }
 
-func isDangerousCommand(command string) bool {
+// dangerousPattern returns the dangerous pattern the command matches, or an
+// empty string when it matches none
+func dangerousPattern(command string) string {
 	dangerousPatterns := []string{
 		"rm -rf /",
 		"rm -rf /usr",
@@ -650,11 +1590,11 @@ func isDangerousCommand(command string) bool {
 	command = strings.ToLower(command)
 	for _, pattern := range dangerousPatterns {
 		if strings.Contains(command, pattern) {
-			return true
+			return pattern
 		}
 	}
 	
-	return false
+	return ""
 }
 
 func getCurrentDirectory() string {
@@ -986,44 +1926,9 @@ func (tm *TaskManager) parseCommandFromResponse(content string) (string, bool) {
 		}
 		
 		if cmd != "" {
-			// Check if it's a safe informational command
-			cmdLower := strings.ToLower(strings.TrimSpace(cmd))
-			
-			// List of safe informational commands that can be auto-executed
-			// These are read-only commands that provide information
-			safeInfoCommands := []string{
-				"who", "w", "users", "whoami", "id",
-				"cat /etc/passwd", "getent passwd", "cut -d: -f1 /etc/passwd",
-				"ls", "pwd", "date", "uptime",
-				"uname", "hostname", "df", "free",
-				"ps", "systemctl list-units", "systemctl status",
-				"netstat", "ss", "ip addr", "ip route",
-			}
-			
-			// Check if command matches or starts with any safe pattern
-			for _, safeCmd := range safeInfoCommands {
-				// Exact match or starts with the safe command (allowing for flags)
-				if cmdLower == safeCmd || strings.HasPrefix(cmdLower, safeCmd+" ") {
-					return cmd, true
-				}
-			}
-			
-			// Also check for common read-only patterns
-			if strings.HasPrefix(cmdLower, "cat ") || 
-			   strings.HasPrefix(cmdLower, "less ") ||
-			   strings.HasPrefix(cmdLower, "head ") ||
-			   strings.HasPrefix(cmdLower, "tail ") ||
-			   strings.HasPrefix(cmdLower, "grep ") ||
-			   strings.HasPrefix(cmdLower, "find ") ||
-			   strings.HasPrefix(cmdLower, "ls ") ||
-			   strings.HasPrefix(cmdLower, "getent ") ||
-			   strings.HasPrefix(cmdLower, "cut ") {
-				// These are generally safe read operations
-				return cmd, true
-			}
-			
-			// For other commands, suggest but don't auto-execute
-			return cmd, false
+			// Only safe informational commands are auto-executed; others
+			// are suggested
+			return cmd, isInfoCommand(cmd)
 		}
 	}
 	
@@ -1052,14 +1957,35 @@ func (tm *TaskManager) parseCommandFromResponse(content string) (string, bool) {
 	return "", false
 }
 
-func CancelTask(taskID string) error {
-	fmt.Printf("Cancelling task: %s\n", taskID)
-	// Placeholder implementation
-	return nil
-}
+// isInfoCommand reports whether a command is one of the read-only
+// informational commands that may run without a tool call
+func isInfoCommand(cmd string) bool {
+	cmdLower := strings.ToLower(strings.TrimSpace(cmd))
 
-func ListTasks() error {
-	fmt.Println("Listing tasks:")
-	// Placeholder implementation  
-	return nil
-}
\ No newline at end of file
+	// List of safe informational commands that can be auto-executed
+	// These are read-only commands that provide information
+	safeInfoCommands := []string{
+		"who", "w", "users", "whoami", "id",
+		"cat /etc/passwd", "getent passwd", "cut -d: -f1 /etc/passwd",
+		"ls", "pwd", "date", "uptime",
+		"uname", "hostname", "df", "free",
+		"ps", "systemctl list-units", "systemctl status",
+		"netstat", "ss", "ip addr", "ip route",
+	}
+
+	// Check if command matches or starts with any safe pattern
+	for _, safeCmd := range safeInfoCommands {
+		// Exact match or starts with the safe command (allowing for flags)
+		if cmdLower == safeCmd || strings.HasPrefix(cmdLower, safeCmd+" ") {
+			return true
+		}
+	}
+
+	// Also check for common read-only patterns
+	for _, prefix := range []string{"cat ", "less ", "head ", "tail ", "grep ", "find ", "ls ", "getent ", "cut "} {
+		if strings.HasPrefix(cmdLower, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// scriptedClient answers the first chat request with a response. Later
+// requests fail, or panic when panics is set, so a panic can be raised from
+// inside tool dispatch.
+type scriptedClient struct {
+	response common.Message
+	panics   bool
+	mu       sync.Mutex
+	calls    int
+}
+
+func (c *scriptedClient) Chat(ctx context.Context, req *common.ChatRequest) (*common.ChatResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if c.calls > 1 && c.panics {
+		panic("summarizer exploded")
+	}
+	if c.calls > 1 {
+		return nil, errors.New("no more scripted responses")
+	}
+	return &common.ChatResponse{Choices: []common.Choice{{Message: c.response}}}, nil
+}
+
+func (c *scriptedClient) Generate(ctx context.Context, req *common.GenerateRequest, onChunk func(*common.GenerateResponse)) (*common.GenerateResponse, error) {
+	panic("unexpected Generate")
+}
+
+func (c *scriptedClient) ListModels(ctx context.Context) (*common.ModelList, error) {
+	return &common.ModelList{}, nil
+}
+
+func (c *scriptedClient) BaseURL() string { return "http://scripted" }
+
+func TestExecuteTaskLogsToolCallOnPanic(t *testing.T) {
+	// The log goes next to the nearest README.md, so keep it in a temp dir
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	// The output is larger than --summarize-output, so dispatchTool asks the
+	// model for a summary, and that second chat request panics
+	client := &scriptedClient{panics: true, response: common.Message{
+		Role: "assistant",
+		ToolCalls: []common.ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: common.FunctionCall{Name: "run_commands", Arguments: `{"command": "echo hello"}`},
+		}},
+	}}
+	tm := NewTaskManager("", "test-model", true, false, TaskOptions{NoRate: true, Quiet: true, SummarizeOutput: 1})
+	tm.tinyllamaClient = client
+
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		tm.ExecuteTask(context.Background(), "say hello")
+	}()
+
+	if recovered != "summarizer exploded" {
+		t.Fatalf("recovered %v, want the panic to be re-raised", recovered)
+	}
+	logs, err := readToolCallLogs(filepath.Join(dir, "tool_calls.log"))
+	if err != nil {
+		t.Fatalf("reading tool_calls.log: %v", err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("got %d log entries, want 1", len(logs))
+	}
+	entry := logs[0]
+	if entry.Status != "error" || entry.ToolName != "run_commands" || entry.UserQuery != "say hello" {
+		t.Errorf("entry = %+v, want an error entry for run_commands", entry)
+	}
+	if entry.ErrorDetails != "panic: summarizer exploded" {
+		t.Errorf("ErrorDetails = %q", entry.ErrorDetails)
+	}
+}
+
+func TestRunCommandsRejectsUserOutsideAllowedUsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		user    string
+		want    string
+	}{
+		{"no users allowed", []string{}, "nobody", "denied"},
+		{"user not listed", []string{"www-data"}, "nobody", "denied"},
+		{"default user", []string{}, "", "success"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := NewTaskManager("", "test-model", true, false, TaskOptions{Quiet: true, AllowedUsers: tt.allowed})
+			args := `{"command": "true"}`
+			if tt.user != "" {
+				args = `{"command": "true", "user": "` + tt.user + `"}`
+			}
+			if got := tm.executeRunCommands(context.Background(), args); got.Status != tt.want {
+				t.Errorf("status = %q (%s), want %q", got.Status, got.Message, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,381 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Statuses of a task in the local registry
+const (
+	TaskRunning     = "running"
+	TaskCompleted   = "completed"
+	TaskFailed      = "failed"
+	TaskCancelled   = "cancelled"
+	TaskInterrupted = "interrupted" // The process exited without recording how
+)
+
+// maxFinishedTasks is how many finished tasks the registry keeps
+const maxFinishedTasks = 100
+
+// TaskIDEnv is set for a detached run to the ID its parent registered, so
+// the background process records its outcome under that ID
+const TaskIDEnv = "TINYPENGUIN_TASK_ID"
+
+// LocalTask is a task started by `run`, recorded so `list` can show it and
+// `cancel` can signal its process
+type LocalTask struct {
+	ID       string     `json:"id"`
+	Query    string     `json:"query"`
+	PID      int        `json:"pid"`
+	Status   string     `json:"status"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Output   string     `json:"output,omitempty"` // File a detached task writes its output to
+}
+
+// taskRegistryPath returns ~/.tinypenguin/tasks.json
+func taskRegistryPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".tinypenguin", "tasks.json"), nil
+}
+
+// taskOutputPath returns ~/.tinypenguin/tasks/<id>.log
+func taskOutputPath(id string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".tinypenguin", "tasks", id+".log"), nil
+}
+
+// updateTasks runs fn on the registry under an exclusive lock, so tasks
+// started and finished by separate processes do not overwrite each other,
+// and writes back what it returns
+func updateTasks(fn func([]LocalTask) []LocalTask) error {
+	path, err := taskRegistryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock the task registry: %w", err)
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	tasks, err := readTasks(path)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(fn(tasks), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tasks.json.*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readTasks loads the registry; a missing file is an empty registry
+func readTasks(path string) ([]LocalTask, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tasks []LocalTask
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return nil, fmt.Errorf("task registry %s is corrupt: %w", path, err)
+	}
+	return tasks, nil
+}
+
+// registerTask records the current process as a running task and returns
+// its ID (task-1, task-2, ...). A detached task also gets an output file.
+func registerTask(query string, detached bool) (LocalTask, error) {
+	var task LocalTask
+	err := updateTasks(func(tasks []LocalTask) []LocalTask {
+		next := 1
+		for _, t := range tasks {
+			if n, err := strconv.Atoi(strings.TrimPrefix(t.ID, "task-")); err == nil && n >= next {
+				next = n + 1
+			}
+		}
+		task = LocalTask{
+			ID:      fmt.Sprintf("task-%d", next),
+			Query:   query,
+			PID:     os.Getpid(),
+			Status:  TaskRunning,
+			Started: time.Now(),
+		}
+		if detached {
+			task.Output, _ = taskOutputPath(task.ID)
+		}
+		return append(pruneFinishedTasks(tasks), task)
+	})
+	return task, err
+}
+
+// setTaskPID records the process that runs a task
+func setTaskPID(id string, pid int) error {
+	return updateTasks(func(tasks []LocalTask) []LocalTask {
+		for i := range tasks {
+			if tasks[i].ID == id {
+				tasks[i].PID = pid
+			}
+		}
+		return tasks
+	})
+}
+
+// StartDetached runs the CLI again in the background with args (the
+// original arguments without --detach), its output going to the task's
+// output file, and returns once the process has started. The new process
+// has its own session, so closing the terminal does not stop it.
+func StartDetached(query string, args []string) (LocalTask, error) {
+	task, err := registerTask(query, true)
+	if err != nil {
+		return task, err
+	}
+	fail := func(err error) (LocalTask, error) {
+		finishTask(task.ID, err)
+		return task, err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fail(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(task.Output), 0700); err != nil {
+		return fail(err)
+	}
+	output, err := os.OpenFile(task.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fail(err)
+	}
+	defer output.Close()
+
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.Env = append(os.Environ(), TaskIDEnv+"="+task.ID)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return fail(fmt.Errorf("failed to start background task: %w", err))
+	}
+	task.PID = cmd.Process.Pid
+	if err := setTaskPID(task.ID, task.PID); err != nil {
+		return task, err
+	}
+	return task, cmd.Process.Release()
+}
+
+// finishTask records how a task ended, from the error its run returned
+func finishTask(id string, runErr error) error {
+	status := TaskCompleted
+	switch {
+	case errors.Is(runErr, ErrCancelled):
+		status = TaskCancelled
+	case runErr != nil:
+		status = TaskFailed
+	}
+	now := time.Now()
+	return updateTasks(func(tasks []LocalTask) []LocalTask {
+		for i := range tasks {
+			if tasks[i].ID == id {
+				tasks[i].Status = status
+				tasks[i].Finished = &now
+			}
+		}
+		return tasks
+	})
+}
+
+// pruneFinishedTasks drops the oldest finished tasks beyond maxFinishedTasks
+func pruneFinishedTasks(tasks []LocalTask) []LocalTask {
+	finished := 0
+	for _, t := range tasks {
+		if t.Status != TaskRunning {
+			finished++
+		}
+	}
+	var kept []LocalTask
+	for _, t := range tasks {
+		if t.Status != TaskRunning && finished > maxFinishedTasks {
+			finished--
+			if t.Output != "" {
+				os.Remove(t.Output)
+			}
+			continue
+		}
+		kept = append(kept, t)
+	}
+	return kept
+}
+
+// processAlive reports whether a process exists; EPERM means it does but
+// belongs to another user
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// reconcileTasks marks running tasks whose process is gone as interrupted,
+// e.g. after a crash or kill -9
+func reconcileTasks(tasks []LocalTask) []LocalTask {
+	for i := range tasks {
+		if tasks[i].Status == TaskRunning && !processAlive(tasks[i].PID) {
+			tasks[i].Status = TaskInterrupted
+		}
+	}
+	return tasks
+}
+
+// ListTasks prints the tasks in the local registry, oldest first
+func ListTasks() error {
+	var tasks []LocalTask
+	err := updateTasks(func(all []LocalTask) []LocalTask {
+		tasks = reconcileTasks(all)
+		return tasks
+	})
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		fmt.Println("📭 No tasks recorded")
+		return nil
+	}
+
+	fmt.Printf("%-10s  %-11s  %7s  %-19s  %s\n", "ID", "STATUS", "PID", "STARTED", "QUERY")
+	for _, t := range tasks {
+		fmt.Printf("%-10s  %s  %7d  %-19s  %s\n", t.ID, colorize(statusColor(taskResultStatus(t.Status)), fmt.Sprintf("%-11s", t.Status)),
+			t.PID, t.Started.Local().Format("2006-01-02 15:04:05"), summarizeArguments(t.Query, 50))
+	}
+	return nil
+}
+
+// taskResultStatus maps a task status to the tool result status whose color
+// it is shown in
+func taskResultStatus(status string) string {
+	switch status {
+	case TaskCompleted, TaskRunning:
+		return "success"
+	case TaskCancelled:
+		return "cancelled"
+	}
+	return "error"
+}
+
+// findTask returns a task from the registry, with its status reconciled
+func findTask(taskID string) (*LocalTask, error) {
+	var task *LocalTask
+	err := updateTasks(func(tasks []LocalTask) []LocalTask {
+		tasks = reconcileTasks(tasks)
+		for i := range tasks {
+			if tasks[i].ID == taskID {
+				found := tasks[i]
+				task = &found
+			}
+		}
+		return tasks
+	})
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, fmt.Errorf("no task %s; see `tinypenguin-cli list`", taskID)
+	}
+	return task, nil
+}
+
+// showTaskOutput prints the output file of a detached task and, with
+// follow, keeps printing what it appends until the task finishes or the
+// viewer is interrupted
+func showTaskOutput(taskID string, follow bool) error {
+	task, err := findTask(taskID)
+	if err != nil {
+		return err
+	}
+	if task.Output == "" {
+		return fmt.Errorf("%s was not started with --detach, so its output was not saved", taskID)
+	}
+	file, err := os.Open(task.Output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := io.Copy(os.Stdout, file); err != nil {
+		return err
+	}
+	if !follow {
+		return nil
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	ticker := time.NewTicker(logFollowInterval)
+	defer ticker.Stop()
+	for task.Status == TaskRunning {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if task, err = findTask(taskID); err != nil {
+			return err
+		}
+		if _, err := io.Copy(os.Stdout, file); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("\n🏁 %s %s\n", taskID, task.Status)
+	return nil
+}
+
+// CancelTask asks a running task to stop by sending its process SIGTERM,
+// which cancels it the same way as Ctrl-C; the task records the outcome
+func CancelTask(taskID string) error {
+	task, err := findTask(taskID)
+	if err != nil {
+		return err
+	}
+	if task.Status != TaskRunning {
+		return fmt.Errorf("%s is not running (%s)", taskID, task.Status)
+	}
+	if err := syscall.Kill(task.PID, syscall.SIGTERM); err != nil {
+		return fmt.Errorf("failed to signal task %s (pid %d): %w", taskID, task.PID, err)
+	}
+	fmt.Printf("🛑 Cancelling task %s (pid %d)\n", taskID, task.PID)
+	return nil
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// utf8BOM is the byte order mark some editors put at the start of UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// textFormat is how a file edited by edit_files stores its text. Diffs are
+// applied to the text with LF line endings in UTF-8 and written back in the
+// file's own convention, so an edit does not convert the whole file.
+type textFormat struct {
+	crlf   bool // Lines end in CRLF; decided by the majority, so a mixed file becomes all CRLF
+	bom    bool // Starts with a UTF-8 byte order mark
+	latin1 bool // Not valid UTF-8, read as ISO-8859-1 so every byte maps to one character
+}
+
+// detectTextFormat works out the format of a file's content, or refuses a
+// binary file, which a line diff would corrupt
+func detectTextFormat(data []byte) (textFormat, error) {
+	var format textFormat
+	if bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF}) {
+		return format, errors.New("it is UTF-16 encoded; convert it to UTF-8 to edit it")
+	}
+	format.bom = bytes.HasPrefix(data, utf8BOM)
+	data = bytes.TrimPrefix(data, utf8BOM)
+
+	control := 0
+	for _, b := range data {
+		if b == 0 {
+			return format, errors.New("it is a binary file (it contains NUL bytes)")
+		}
+		if b < 0x20 && b != '\n' && b != '\t' && b != '\r' && b != '\f' {
+			control++
+		}
+	}
+	if len(data) > 0 && float64(control)/float64(len(data)) > binaryThreshold {
+		return format, errors.New("it is a binary file (mostly control characters)")
+	}
+
+	format.latin1 = !utf8.Valid(data)
+	crlf := bytes.Count(data, []byte("\r\n"))
+	format.crlf = crlf > 0 && crlf >= bytes.Count(data, []byte("\n"))-crlf
+	return format, nil
+}
+
+// decode turns file content into the LF, UTF-8 text diffs are applied to
+func (f textFormat) decode(data []byte) string {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	text := string(data)
+	if f.latin1 {
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	}
+	if f.crlf {
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+	}
+	return text
+}
+
+// encode turns edited text back into file content in the original format.
+// It fails when the text has characters an ISO-8859-1 file cannot hold.
+func (f textFormat) encode(text string) ([]byte, error) {
+	if f.crlf {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	}
+	var data []byte
+	if f.bom {
+		data = append(data, utf8BOM...)
+	}
+	if !f.latin1 {
+		return append(data, text...), nil
+	}
+	for _, r := range text {
+		if r > 0xFF {
+			return nil, fmt.Errorf("the edit adds %q, which the file's ISO-8859-1 encoding cannot hold", r)
+		}
+		data = append(data, byte(r))
+	}
+	return data, nil
+}
+
+// String describes the format for tool results, e.g. "UTF-8, CRLF"
+func (f textFormat) String() string {
+	parts := []string{"UTF-8"}
+	if f.latin1 {
+		parts[0] = "ISO-8859-1"
+	} else if f.bom {
+		parts[0] = "UTF-8 with BOM"
+	}
+	if f.crlf {
+		parts = append(parts, "CRLF")
+	} else {
+		parts = append(parts, "LF")
+	}
+	return strings.Join(parts, ", ")
+}
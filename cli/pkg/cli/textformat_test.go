@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTextFormatCRLFWithBOMRoundTrip(t *testing.T) {
+	data := []byte("\xEF\xBB\xBFfirst\r\nsecond\r\nthird\r\n")
+	format, err := detectTextFormat(data)
+	if err != nil {
+		t.Fatalf("detectTextFormat: %v", err)
+	}
+	if !format.crlf || !format.bom || format.latin1 {
+		t.Fatalf("format = %+v, want CRLF with BOM", format)
+	}
+
+	diff := "--- a/f.txt\n+++ b/f.txt\n@@ -1,3 +1,4 @@\n first\n-second\n+second changed\n+inserted\n third\n"
+	updated, err := applyDiff(format.decode(data), diff)
+	if err != nil {
+		t.Fatalf("applyDiff: %v", err)
+	}
+	encoded, err := format.encode(updated)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	want := "\xEF\xBB\xBFfirst\r\nsecond changed\r\ninserted\r\nthird\r\n"
+	if string(encoded) != want {
+		t.Errorf("encoded = %q, want %q", encoded, want)
+	}
+	if !bytes.HasPrefix(encoded, utf8BOM) {
+		t.Error("the byte order mark was dropped")
+	}
+	body := strings.TrimSuffix(string(bytes.TrimPrefix(encoded, utf8BOM)), "\r\n")
+	for i, line := range strings.Split(body, "\r\n") {
+		if strings.ContainsAny(line, "\r\n") {
+			t.Errorf("line %d %q does not end in CRLF", i+1, line)
+		}
+	}
+}
+
+func TestDetectTextFormatRefusesNUL(t *testing.T) {
+	if _, err := detectTextFormat([]byte("text\x00more text\n")); err == nil {
+		t.Error("a file with NUL bytes was accepted")
+	}
+}
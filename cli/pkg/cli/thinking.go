@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultThinkingTags are the reasoning blocks removed by --strip-thinking
+// unless other tags are configured
+var DefaultThinkingTags = []string{"think", "thinking", "reasoning"}
+
+// stripThinking removes <tag>...</tag> reasoning blocks from a model answer
+// and returns the cleaned content and the removed reasoning. A closing tag
+// without an opening one drops everything before it, since some models omit
+// the opening tag; an unclosed block drops the rest of the answer.
+func stripThinking(content string, tags []string) (string, []string) {
+	var thoughts []string
+	for _, tag := range tags {
+		openTag, closeTag := "<"+tag+">", "</"+tag+">"
+		block := regexp.MustCompile(`(?s)` + regexp.QuoteMeta(openTag) + `(.*?)` + regexp.QuoteMeta(closeTag))
+		for _, m := range block.FindAllStringSubmatch(content, -1) {
+			thoughts = append(thoughts, strings.TrimSpace(m[1]))
+		}
+		content = block.ReplaceAllString(content, "")
+
+		if i := strings.Index(content, closeTag); i >= 0 && !strings.Contains(content[:i], openTag) {
+			thoughts = append(thoughts, strings.TrimSpace(content[:i]))
+			content = content[i+len(closeTag):]
+		}
+		if i := strings.Index(content, openTag); i >= 0 {
+			thoughts = append(thoughts, strings.TrimSpace(content[i+len(openTag):]))
+			content = content[:i]
+		}
+	}
+	return strings.TrimSpace(content), thoughts
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+// toolUsagePrompt builds the tool section of the system prompt from the tool
+// definitions: one line per tool with its parameters, optional ones marked
+// with "?". The tools field already carries the full schemas, so this only
+// names them and says how to call them. inText is for models without tool
+// calling, which must write the call as JSON in their reply (read back by
+// extractToolCallsFromContent). With examples set, two worked calls are added.
+// Without list the tools are not named at all, leaving them to the tools
+// field, for models that do worse when the tools are described twice.
+func toolUsagePrompt(tools []common.Tool, inText, examples, list bool) string {
+	var sb strings.Builder
+	if inText {
+		sb.WriteString(`Tool calling is not available in this session. To use a tool, reply with only a JSON object and no other text: {"name": "<tool>", "arguments": {<parameters>}}.` + "\n")
+	} else {
+		sb.WriteString("Act through tool calls, never by writing JSON in your reply.\n")
+	}
+	sb.WriteString("Use run_commands for every command, including informational ones.\n")
+	if list {
+		sb.WriteString("Tools:\n")
+		for _, tool := range tools {
+			fmt.Fprintf(&sb, "- %s: %s\n", toolSignature(tool), tool.Function.Description)
+		}
+	}
+
+	if examples {
+		sb.WriteString("Examples:\n")
+		for _, ex := range []struct{ query, tool, arguments string }{
+			{"Check current users", "run_commands", `{"command": "who"}`},
+			{"Set PermitRootLogin no in /etc/ssh/sshd_config", "edit_files",
+				`{"path": "/etc/ssh/sshd_config", "diff": "<<<<<<< SEARCH\nPermitRootLogin yes\n=======\nPermitRootLogin no\n>>>>>>> REPLACE"}`},
+		} {
+			if inText {
+				fmt.Fprintf(&sb, "User: %s\nReply: {\"name\": %q, \"arguments\": %s}\n", ex.query, ex.tool, ex.arguments)
+			} else {
+				fmt.Fprintf(&sb, "User: %s\nCall: %s %s\n", ex.query, ex.tool, ex.arguments)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// toolSignature renders a tool as name(required, optional?), required
+// parameters in declared order and optional ones sorted
+func toolSignature(tool common.Tool) string {
+	required := requiredFields(tool.Function.Parameters)
+	params := slices.Clone(required)
+
+	props, _ := tool.Function.Parameters["properties"].(map[string]interface{})
+	var optional []string
+	for name := range props {
+		if !slices.Contains(required, name) {
+			optional = append(optional, name)
+		}
+	}
+	sort.Strings(optional)
+	for _, name := range optional {
+		params = append(params, name+"?")
+	}
+	return fmt.Sprintf("%s(%s)", tool.Function.Name, strings.Join(params, ", "))
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"context"
+	"sync"
+
+	"example.com/tinypenguin/pkg/common"
+)
+
+type usageKey struct{}
+
+// usageTotal accumulates token usage; parallel tool calls may add to it at
+// once, e.g. when their outputs are summarized
+type usageTotal struct {
+	mu    sync.Mutex
+	usage common.Usage
+}
+
+// get returns the usage added so far
+func (u *usageTotal) get() common.Usage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.usage
+}
+
+// withUsage returns a context that accumulates the token usage of every chat
+// request made with it, so a task can report its total
+func withUsage(ctx context.Context) (context.Context, *usageTotal) {
+	total := &usageTotal{}
+	return context.WithValue(ctx, usageKey{}, total), total
+}
+
+// recordUsage adds a response's usage to the context's accumulator, if any
+func recordUsage(ctx context.Context, usage common.Usage) {
+	if total, ok := ctx.Value(usageKey{}).(*usageTotal); ok {
+		total.mu.Lock()
+		defer total.mu.Unlock()
+		total.usage.PromptTokens += usage.PromptTokens
+		total.usage.CompletionTokens += usage.CompletionTokens
+		total.usage.TotalTokens += usage.TotalTokens
+	}
+}
+
+// printUsage prints the token usage summary of a task
+func (tm *TaskManager) printUsage(usage common.Usage) {
+	if usage.TotalTokens == 0 {
+		return
+	}
+	tm.progressf("📈 Tokens: %d prompt + %d completion = %d\n", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens)
+}
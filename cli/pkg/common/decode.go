@@ -0,0 +1,67 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrResponseTooLarge is returned when a response body exceeds the client's
+// size limit
+var ErrResponseTooLarge = errors.New("response too large")
+
+// maxBodySnippet bounds how much of an undecodable body is quoted in errors
+const maxBodySnippet = 200
+
+// decodeBody reads a whole response body and decodes its first JSON value.
+// Anything after it, such as whitespace or a stray [DONE] that some
+// OpenAI-compatible servers append, is ignored.
+func decodeBody(body io.Reader, v interface{}) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	data = bytes.TrimSpace(data)
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w (body: %q)", err, bodySnippet(data))
+	}
+	return nil
+}
+
+// limitBody wraps a response body so reading fails with ErrResponseTooLarge
+// once more than the client's limit has been read
+func (c *TinyllamaClient) limitBody(body io.ReadCloser) io.ReadCloser {
+	return &limitedBody{ReadCloser: body, limit: c.maxResponseBytes}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.read > b.limit {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, b.limit)
+	}
+	// Read at most one byte past the limit, enough to detect the overflow
+	if room := b.limit + 1 - b.read; int64(len(p)) > room {
+		p = p[:room]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return n, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, b.limit)
+	}
+	return n, err
+}
+
+// bodySnippet returns the start of a response body for error messages
+func bodySnippet(data []byte) string {
+	if len(data) > maxBodySnippet {
+		return string(data[:maxBodySnippet]) + "..."
+	}
+	return string(data)
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors an *APIError matches with errors.Is
+var (
+	ErrModelNotFound = errors.New("model not found")
+	ErrUnauthorized  = errors.New("unauthorized")
+	ErrRateLimited   = errors.New("rate limited")
+	ErrServerError   = errors.New("server error")
+)
+
+// APIError is a non-200 response from the model server. Message and Type
+// come from an OpenAI-style {"error":{"message","type"}} body or an Ollama
+// {"error":"..."} body; otherwise Message is the raw body.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Type       string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// Is lets callers test for the error classes with errors.Is
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrModelNotFound:
+		msg := strings.ToLower(e.Message)
+		return e.StatusCode == http.StatusNotFound ||
+			e.Type == "model_not_found" ||
+			strings.Contains(msg, "model") && strings.Contains(msg, "not found")
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServerError:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// newAPIError reads the body of a failed response into an *APIError
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(resp.Body)
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+		Body:       string(body),
+	}
+
+	var parsed struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &parsed) != nil || len(parsed.Error) == 0 {
+		return apiErr
+	}
+	var detail struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	}
+	var message string
+	if json.Unmarshal(parsed.Error, &message) == nil && message != "" {
+		apiErr.Message = message
+	} else if json.Unmarshal(parsed.Error, &detail) == nil && detail.Message != "" {
+		apiErr.Message = detail.Message
+		apiErr.Type = detail.Type
+	}
+	return apiErr
+}
@@ -0,0 +1,109 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// redactedHeaders are written to the trace as "[redacted]"
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Api-Key", "X-Api-Key", "Cookie"}
+
+// WithHTTPTrace makes the client write every HTTP exchange to w: the request
+// line, headers with credentials redacted and body, then the response status,
+// headers and body. The response body is copied as the client reads it, so
+// streamed responses are traced without being buffered first.
+func (c *TinyllamaClient) WithHTTPTrace(w io.Writer) *TinyllamaClient {
+	next := c.httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c.httpClient.Transport = &traceTransport{next: next, w: w}
+	return c
+}
+
+// traceTransport is the http.RoundTripper installed by WithHTTPTrace
+type traceTransport struct {
+	next http.RoundTripper
+	mu   sync.Mutex // Keeps the lines of one message together
+	w    io.Writer
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		// Hand the request an unread copy of the body
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "> %s %s %s\n", req.Method, req.URL, req.Proto)
+	writeTraceHeaders(&sb, "> ", req.Header)
+	t.write(sb.String() + string(body) + "\n")
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.write(fmt.Sprintf("! %v\n", err))
+		return nil, err
+	}
+
+	sb.Reset()
+	fmt.Fprintf(&sb, "< %s %s\n", resp.Proto, resp.Status)
+	writeTraceHeaders(&sb, "< ", resp.Header)
+	t.write(sb.String())
+	resp.Body = &tracedBody{ReadCloser: resp.Body, t: t}
+	return resp, nil
+}
+
+func (t *traceTransport) write(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.w, s)
+}
+
+// writeTraceHeaders writes headers sorted by name, hiding credentials
+func writeTraceHeaders(sb *strings.Builder, prefix string, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			if slices.Contains(redactedHeaders, http.CanonicalHeaderKey(name)) {
+				value = "[redacted]"
+			}
+			fmt.Fprintf(sb, "%s%s: %s\n", prefix, name, value)
+		}
+	}
+	sb.WriteString(prefix + "\n")
+}
+
+// tracedBody copies a response body to the trace as it is read
+type tracedBody struct {
+	io.ReadCloser
+	t *traceTransport
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.t.write(string(p[:n]))
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	b.t.write("\n")
+	return b.ReadCloser.Close()
+}
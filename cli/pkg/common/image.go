@@ -0,0 +1,77 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Image is an image attached to a chat message
+type Image struct {
+	MediaType string // e.g. image/png
+	Data      string // Base64-encoded image bytes
+}
+
+// DataURL returns the image as a data: URL for OpenAI-style content parts
+func (i Image) DataURL() string {
+	return fmt.Sprintf("data:%s;base64,%s", i.MediaType, i.Data)
+}
+
+// LoadImage resolves an image reference, either a data: URL or a file path,
+// to a base64-encoded Image
+func LoadImage(ref string) (Image, error) {
+	if rest, ok := strings.CutPrefix(ref, "data:"); ok {
+		mediaType, data, ok := strings.Cut(rest, ";base64,")
+		if !ok {
+			return Image{}, fmt.Errorf("unsupported data URL: only base64 images are accepted")
+		}
+		if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+			return Image{}, fmt.Errorf("invalid base64 image data: %w", err)
+		}
+		return Image{MediaType: mediaType, Data: data}, nil
+	}
+
+	content, err := os.ReadFile(ref)
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	mediaType := http.DetectContentType(content)
+	if !strings.HasPrefix(mediaType, "image/") {
+		return Image{}, fmt.Errorf("%s is not an image (detected %s)", ref, mediaType)
+	}
+	return Image{MediaType: mediaType, Data: base64.StdEncoding.EncodeToString(content)}, nil
+}
+
+// contentPart is one element of an OpenAI content-parts array
+type contentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+}
+
+// MarshalJSON encodes the content as a parts array when images are attached
+// and as a plain string otherwise, which every server accepts
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	if len(m.Images) == 0 {
+		return json.Marshal(plain(m))
+	}
+
+	parts := []contentPart{{Type: "text", Text: m.Content}}
+	for _, img := range m.Images {
+		part := contentPart{Type: "image_url"}
+		part.ImageURL = &struct {
+			URL string `json:"url"`
+		}{URL: img.DataURL()}
+		parts = append(parts, part)
+	}
+	return json.Marshal(struct {
+		plain
+		Content []contentPart `json:"content"`
+	}{plain(m), parts})
+}
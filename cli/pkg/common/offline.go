@@ -0,0 +1,122 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// OfflineClient is an LLMClient that never touches the network. Chat requests
+// are appended as JSON lines to the record file, when set, and answered from
+// the canned responses in the replay file, in order; the last response is
+// repeated once they run out. Without a replay file every chat gets a fixed
+// answer saying the request was recorded.
+type OfflineClient struct {
+	mu         sync.Mutex
+	recordPath string
+	replayPath string
+	responses  []ChatResponse
+	next       int
+}
+
+// NewOfflineClient creates an offline client. The replay file holds one or
+// more ChatResponse JSON objects, one after another or one per line.
+func NewOfflineClient(recordPath, replayPath string) (*OfflineClient, error) {
+	c := &OfflineClient{recordPath: recordPath, replayPath: replayPath}
+	if replayPath == "" {
+		return c, nil
+	}
+
+	file, err := os.Open(replayPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replay file: %w", err)
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	for {
+		var resp ChatResponse
+		if err := decoder.Decode(&resp); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("invalid replay file %s: %w", replayPath, err)
+		}
+		c.responses = append(c.responses, resp)
+	}
+	if len(c.responses) == 0 {
+		return nil, fmt.Errorf("replay file %s holds no responses", replayPath)
+	}
+	return c, nil
+}
+
+// BaseURL describes where responses come from, for messages
+func (c *OfflineClient) BaseURL() string {
+	if c.replayPath != "" {
+		return "offline replay of " + c.replayPath
+	}
+	return "offline"
+}
+
+// Chat records the request and returns the next canned response
+func (c *OfflineClient) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.recordPath != "" {
+		if err := appendJSONLine(c.recordPath, req); err != nil {
+			return nil, fmt.Errorf("failed to record request: %w", err)
+		}
+	}
+
+	if len(c.responses) == 0 {
+		return &ChatResponse{
+			Model: req.Model,
+			Choices: []Choice{{
+				Message:      Message{Role: "assistant", Content: fmt.Sprintf("Offline: the request was recorded to %s and not sent to a model.", c.recordPath)},
+				FinishReason: "stop",
+			}},
+		}, nil
+	}
+	resp := c.responses[c.next]
+	if c.next < len(c.responses)-1 {
+		c.next++
+	}
+	if resp.Model == "" {
+		resp.Model = req.Model
+	}
+	return &resp, nil
+}
+
+// Generate is not available offline
+func (c *OfflineClient) Generate(ctx context.Context, req *GenerateRequest, onChunk func(*GenerateResponse)) (*GenerateResponse, error) {
+	return nil, fmt.Errorf("generate is not available in offline mode")
+}
+
+// ListModels is not available offline
+func (c *OfflineClient) ListModels(ctx context.Context) (*ModelList, error) {
+	return nil, fmt.Errorf("listing models is not available in offline mode")
+}
+
+// appendJSONLine appends v to path as a single JSON line
+func appendJSONLine(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,258 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API styles supported by the client
+const (
+	APIStyleOpenAI = "openai" // OpenAI-compatible /v1/chat/completions
+	APIStyleOllama = "ollama" // Ollama native /api/chat
+)
+
+// KeepAlive is how long Ollama keeps a model loaded after a native request:
+// a duration such as "10m", or a number of seconds where -1 keeps it loaded
+// until the server stops. Empty leaves it to the server (OLLAMA_KEEP_ALIVE).
+type KeepAlive string
+
+// ParseKeepAlive validates a keep-alive setting
+func ParseKeepAlive(s string) (KeepAlive, error) {
+	if _, err := strconv.Atoi(s); err == nil || s == "" {
+		return KeepAlive(s), nil
+	}
+	if _, err := time.ParseDuration(s); err != nil {
+		return "", fmt.Errorf("keep-alive must be a duration such as 10m or a number of seconds (-1 for forever), got %q", s)
+	}
+	return KeepAlive(s), nil
+}
+
+// MarshalJSON sends a plain number of seconds as a JSON number, since
+// Ollama reads strings as durations, which need a unit
+func (k KeepAlive) MarshalJSON() ([]byte, error) {
+	if n, err := strconv.Atoi(string(k)); err == nil {
+		return json.Marshal(n)
+	}
+	return json.Marshal(string(k))
+}
+
+// ollamaChatRequest is the request body of Ollama's native /api/chat
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"` // Must be sent explicitly; Ollama streams by default
+	Tools    []Tool          `json:"tools,omitempty"`
+	Options  *ollamaOptions  `json:"options,omitempty"`
+
+	KeepAlive KeepAlive `json:"keep_alive,omitempty"`
+}
+
+// ollamaOptions holds the model parameters of a native request
+type ollamaOptions struct {
+	Seed *int `json:"seed,omitempty"`
+}
+
+// ollamaMessage is a chat message in the native schema. Tool call arguments
+// are JSON objects rather than encoded strings, and calls carry no IDs.
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// ollamaChatResponse is the non-streaming response of /api/chat
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	CreatedAt       time.Time     `json:"created_at"`
+	Message         ollamaMessage `json:"message"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+// SetAPIStyle selects the OpenAI-compatible or Ollama native API. With the
+// native API a trailing /v1 is dropped from the base URL.
+func (c *TinyllamaClient) SetAPIStyle(style string) {
+	c.apiStyle = style
+	if style == APIStyleOllama {
+		c.baseURL = nativeBaseURL(c.baseURL)
+	}
+}
+
+// nativeBaseURL strips the OpenAI-compatible /v1 suffix to reach Ollama's
+// native /api endpoints
+func nativeBaseURL(baseURL string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/v1")
+}
+
+// chatOllama sends a chat request to the native /api/chat endpoint and maps
+// the response back to the OpenAI-style ChatResponse
+func (c *TinyllamaClient) chatOllama(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	url := fmt.Sprintf("%s/api/chat", c.baseURL)
+
+	body, err := json.Marshal(toOllamaRequest(req))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	resp.Body = c.limitBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var ollamaResp ollamaChatResponse
+	if err := decodeBody(resp.Body, &ollamaResp); err != nil {
+		return nil, err
+	}
+
+	return fromOllamaResponse(&ollamaResp), nil
+}
+
+// toOllamaRequest converts a ChatRequest to the native schema
+func toOllamaRequest(req *ChatRequest) *ollamaChatRequest {
+	out := &ollamaChatRequest{
+		Model:     req.Model,
+		Tools:     req.Tools,
+		KeepAlive: req.KeepAlive,
+	}
+	if req.Seed != nil {
+		out.Options = &ollamaOptions{Seed: req.Seed}
+	}
+	// The native API has no tool_choice; "none" is honored by not offering
+	// tools, other choices are left to the model
+	if req.ToolChoice == "none" {
+		out.Tools = nil
+	}
+
+	// Tool results are matched to their call by name in the native API
+	callNames := make(map[string]string)
+	for _, msg := range req.Messages {
+		m := ollamaMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+		for _, img := range msg.Images {
+			m.Images = append(m.Images, img.Data)
+		}
+		for _, tc := range msg.ToolCalls {
+			callNames[tc.ID] = tc.Function.Name
+			var call ollamaToolCall
+			call.Function.Name = tc.Function.Name
+			call.Function.Arguments = json.RawMessage(tc.Function.Arguments)
+			if !json.Valid(call.Function.Arguments) {
+				call.Function.Arguments = json.RawMessage("{}")
+			}
+			m.ToolCalls = append(m.ToolCalls, call)
+		}
+		if msg.Role == "tool" {
+			m.ToolName = callNames[msg.ToolCallID]
+		}
+		out.Messages = append(out.Messages, m)
+	}
+	return out
+}
+
+// fromOllamaResponse converts a native response to a ChatResponse, encoding
+// tool call arguments as strings and assigning call IDs
+func fromOllamaResponse(resp *ollamaChatResponse) *ChatResponse {
+	msg := Message{
+		Role:    resp.Message.Role,
+		Content: resp.Message.Content,
+	}
+	for i, tc := range resp.Message.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+			ID:   fmt.Sprintf("call_%d", i),
+			Type: "function",
+			Function: FunctionCall{
+				Name:      tc.Function.Name,
+				Arguments: string(tc.Function.Arguments),
+			},
+		})
+	}
+
+	finishReason := resp.DoneReason
+	if len(msg.ToolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+
+	return &ChatResponse{
+		Object:  "chat.completion",
+		Created: resp.CreatedAt.Unix(),
+		Model:   resp.Model,
+		Choices: []Choice{{
+			Message:      msg,
+			FinishReason: finishReason,
+		}},
+		Usage: Usage{
+			PromptTokens:     resp.PromptEvalCount,
+			CompletionTokens: resp.EvalCount,
+			TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+		},
+	}
+}
+
+// ModelCapabilities asks Ollama's native /api/show which features a model
+// supports, e.g. "completion", "tools" or "vision". It returns nil without an
+// error when the server is too old to report capabilities.
+func (c *TinyllamaClient) ModelCapabilities(ctx context.Context, model string) ([]string, error) {
+	url := fmt.Sprintf("%s/api/show", nativeBaseURL(c.baseURL))
+
+	body, err := json.Marshal(map[string]string{"model": model})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	resp.Body = c.limitBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var show struct {
+		Capabilities []string `json:"capabilities"`
+	}
+	if err := decodeBody(resp.Body, &show); err != nil {
+		return nil, err
+	}
+	return show.Capabilities, nil
+}
@@ -5,20 +5,37 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 )
 
 const (
 	DefaultTinyllamaURL = "http://localhost:11434/v1"
 	DefaultTimeout      = 30 * time.Second
+
+	// DefaultMaxResponseBytes caps how much of a response the client reads,
+	// so a misbehaving endpoint cannot exhaust memory
+	DefaultMaxResponseBytes = 32 << 20
 )
 
+// LLMClient is the model API used by the task manager. TinyllamaClient talks
+// to a server; OfflineClient records requests and replays canned responses.
+type LLMClient interface {
+	Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
+	Generate(ctx context.Context, req *GenerateRequest, onChunk func(*GenerateResponse)) (*GenerateResponse, error)
+	ListModels(ctx context.Context) (*ModelList, error)
+	BaseURL() string
+}
+
 // TinyllamaClient handles communication with the tinyllama API
 type TinyllamaClient struct {
 	baseURL    string
 	httpClient *http.Client
+	apiStyle   string
+
+	maxResponseBytes int64
 }
 
 // ChatRequest represents a chat completion request
@@ -27,6 +44,36 @@ type ChatRequest struct {
 	Messages []Message   `json:"messages"`
 	Stream   bool        `json:"stream,omitempty"`
 	Tools    []Tool      `json:"tools,omitempty"`
+	Seed     *int        `json:"seed,omitempty"` // Sampling seed, for reproducible or deliberately varied answers
+	// ToolChoice is "auto", "none", "required" or a ToolChoiceFunction; nil
+	// leaves the decision to the model
+	ToolChoice interface{} `json:"tool_choice,omitempty"`
+	// KeepAlive is only sent by the native API; the OpenAI-compatible one
+	// has no equivalent
+	KeepAlive KeepAlive `json:"-"`
+}
+
+// ToolChoiceFunction forces the model to call the named function
+type ToolChoiceFunction struct {
+	Type     string `json:"type"` // Always "function"
+	Function struct {
+		Name string `json:"name"`
+	} `json:"function"`
+}
+
+// NewToolChoice converts a tool choice setting to its request value: "auto",
+// "none" and "required" are sent as they are, anything else names the
+// function the model must call
+func NewToolChoice(choice string) interface{} {
+	switch choice {
+	case "":
+		return nil
+	case "auto", "none", "required":
+		return choice
+	}
+	forced := ToolChoiceFunction{Type: "function"}
+	forced.Function.Name = choice
+	return forced
 }
 
 // Message represents a chat message
@@ -34,6 +81,8 @@ type Message struct {
 	Role    string     `json:"role"`
 	Content string     `json:"content"`
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string  `json:"tool_call_id,omitempty"` // Set on tool role messages
+	Images  []Image    `json:"-"` // Sent as content parts, see MarshalJSON
 }
 
 // Tool represents a function tool definition
@@ -62,6 +111,40 @@ type FunctionCall struct {
 	Arguments string `json:"arguments"`
 }
 
+// UnmarshalJSON accepts arguments both as a JSON-encoded string, as the
+// OpenAI API specifies, and as a plain JSON object, which some servers and
+// small models send instead
+func (f *FunctionCall) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	f.Name = raw.Name
+	f.Arguments = decodeArguments(raw.Arguments)
+	return nil
+}
+
+// decodeArguments returns tool call arguments as a string: a JSON string is
+// unquoted, anything else is kept as compact JSON text
+func decodeArguments(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err == nil {
+		return buf.String()
+	}
+	return string(raw)
+}
+
 // ChatResponse represents a chat completion response
 type ChatResponse struct {
 	ID      string   `json:"id"`
@@ -98,11 +181,28 @@ func NewTinyllamaClient(baseURL string) *TinyllamaClient {
 		httpClient: &http.Client{
 			Timeout: DefaultTimeout,
 		},
+		apiStyle: APIStyleOpenAI,
+
+		maxResponseBytes: DefaultMaxResponseBytes,
 	}
 }
 
+// SetMaxResponseBytes sets the largest response body the client will read
+func (c *TinyllamaClient) SetMaxResponseBytes(n int64) {
+	c.maxResponseBytes = n
+}
+
+// BaseURL returns the API endpoint the client talks to
+func (c *TinyllamaClient) BaseURL() string {
+	return c.baseURL
+}
+
 // Chat creates a chat completion
 func (c *TinyllamaClient) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if c.apiStyle == APIStyleOllama {
+		return c.chatOllama(ctx, req)
+	}
+
 	url := fmt.Sprintf("%s/chat/completions", c.baseURL)
 	
 	body, err := json.Marshal(req)
@@ -122,15 +222,15 @@ func (c *TinyllamaClient) Chat(ctx context.Context, req *ChatRequest) (*ChatResp
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
+	resp.Body = c.limitBody(resp.Body)
 	
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp)
 	}
 	
 	var chatResp ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := decodeBody(resp.Body, &chatResp); err != nil {
+		return nil, err
 	}
 	
 	return &chatResp, nil
@@ -140,7 +240,9 @@ func (c *TinyllamaClient) Chat(ctx context.Context, req *ChatRequest) (*ChatResp
 type GenerateRequest struct {
 	Model  string `json:"model"`
 	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream,omitempty"`
+	Stream bool   `json:"stream"` // Sent explicitly; Ollama streams by default
+
+	KeepAlive KeepAlive `json:"keep_alive,omitempty"`
 }
 
 type GenerateResponse struct {
@@ -156,15 +258,31 @@ type GenerateResponse struct {
 	EvalDuration       int64 `json:"eval_duration"`
 }
 
-// Generate creates a text generation
-func (c *TinyllamaClient) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
-	url := fmt.Sprintf("%s/generate", c.baseURL)
+// Generate creates a text generation using Ollama's native /api/generate.
+// With req.Stream set, onChunk (if not nil) is called for every partial
+// response and the returned response holds the full text and final stats.
+func (c *TinyllamaClient) Generate(ctx context.Context, req *GenerateRequest, onChunk func(*GenerateResponse)) (*GenerateResponse, error) {
+	url := fmt.Sprintf("%s/api/generate", nativeBaseURL(c.baseURL))
 	
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 	
+	// A stream lasts as long as the model writes, so the client's overall
+	// timeout would cut it off; only waiting for the response is bounded
+	client := c.httpClient
+	var waiting *time.Timer
+	if req.Stream && client.Timeout > 0 {
+		streaming := *client
+		streaming.Timeout = 0
+		client = &streaming
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		waiting = time.AfterFunc(c.httpClient.Timeout, cancel)
+	}
+
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -172,28 +290,77 @@ func (c *TinyllamaClient) Generate(ctx context.Context, req *GenerateRequest) (*
 	
 	httpReq.Header.Set("Content-Type", "application/json")
 	
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := client.Do(httpReq)
+	if waiting != nil && !waiting.Stop() {
+		if err == nil {
+			resp.Body.Close()
+		}
+		return nil, fmt.Errorf("failed to execute request: no response within %s", c.httpClient.Timeout)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
+	resp.Body = c.limitBody(resp.Body)
 	
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp)
 	}
 	
-	var genResp GenerateResponse
-	if err := json.NewDecoder(resp.Body).Decode(&genResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if !req.Stream {
+		var genResp GenerateResponse
+		if err := decodeBody(resp.Body, &genResp); err != nil {
+			return nil, err
+		}
+		if onChunk != nil {
+			onChunk(&genResp)
+		}
+		return &genResp, nil
+	}
+
+	// A streamed response is one JSON object per line; the last has done set
+	decoder := json.NewDecoder(resp.Body)
+	var text strings.Builder
+	for {
+		var genResp GenerateResponse
+		if err := decoder.Decode(&genResp); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		text.WriteString(genResp.Response)
+		if onChunk != nil {
+			onChunk(&genResp)
+		}
+		if genResp.Done {
+			genResp.Response = text.String()
+			return &genResp, nil
+		}
 	}
-	
-	return &genResp, nil
 }
 
 // ListModels lists available models
 type ModelList struct {
 	Models []ModelInfo `json:"models"`
+	// Data holds the models when the endpoint uses the OpenAI list format
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// Names returns the model names from either list format, without duplicates
+func (l *ModelList) Names() []string {
+	var names []string
+	add := func(name string) {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, m := range l.Models {
+		add(m.Name)
+	}
+	for _, m := range l.Data {
+		add(m.ID)
+	}
+	return names
 }
 
 type ModelInfo struct {
@@ -204,6 +371,9 @@ type ModelInfo struct {
 
 func (c *TinyllamaClient) ListModels(ctx context.Context) (*ModelList, error) {
 	url := fmt.Sprintf("%s/models", c.baseURL)
+	if c.apiStyle == APIStyleOllama {
+		url = fmt.Sprintf("%s/api/tags", c.baseURL)
+	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -215,15 +385,15 @@ func (c *TinyllamaClient) ListModels(ctx context.Context) (*ModelList, error) {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
+	resp.Body = c.limitBody(resp.Body)
 	
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp)
 	}
 	
 	var modelList ModelList
-	if err := json.NewDecoder(resp.Body).Decode(&modelList); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := decodeBody(resp.Body, &modelList); err != nil {
+		return nil, err
 	}
 	
 	return &modelList, nil
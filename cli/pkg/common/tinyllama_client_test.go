@@ -0,0 +1,100 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFunctionCallUnmarshalArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{
+			name: "object",
+			json: `{"name": "run_commands", "arguments": {"command": "ls -l", "timeout": 30}}`,
+			want: `{"command":"ls -l","timeout":30}`,
+		},
+		{
+			name: "string",
+			json: `{"name": "run_commands", "arguments": "{\"command\": \"ls -l\", \"timeout\": 30}"}`,
+			want: `{"command": "ls -l", "timeout": 30}`,
+		},
+		{
+			name: "null",
+			json: `{"name": "run_commands", "arguments": null}`,
+			want: "",
+		},
+		{
+			name: "missing",
+			json: `{"name": "run_commands"}`,
+			want: "",
+		},
+		{
+			name: "empty string",
+			json: `{"name": "run_commands", "arguments": ""}`,
+			want: "",
+		},
+		{
+			name: "empty object",
+			json: `{"name": "run_commands", "arguments": {}}`,
+			want: "{}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f FunctionCall
+			if err := json.Unmarshal([]byte(tt.json), &f); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if f.Name != "run_commands" {
+				t.Errorf("Name = %q, want run_commands", f.Name)
+			}
+			if f.Arguments != tt.want {
+				t.Errorf("Arguments = %q, want %q", f.Arguments, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateStreamOutlastsClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := range 5 {
+			fmt.Fprintf(w, "{\"response\": \"%d\", \"done\": %v}\n", i, i == 4)
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	client := NewTinyllamaClient(server.URL)
+	client.httpClient.Timeout = 100 * time.Millisecond
+	resp, err := client.Generate(context.Background(), &GenerateRequest{Model: "m", Prompt: "p", Stream: true}, nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if resp.Response != "01234" {
+		t.Errorf("Response = %q, want 01234", resp.Response)
+	}
+}
+
+func TestGenerateStreamBoundsWaitForResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := NewTinyllamaClient(server.URL)
+	client.httpClient.Timeout = 50 * time.Millisecond
+	_, err := client.Generate(context.Background(), &GenerateRequest{Model: "m", Prompt: "p", Stream: true}, nil)
+	if err == nil || !strings.Contains(err.Error(), "no response within") {
+		t.Errorf("err = %v, want a timeout waiting for the response", err)
+	}
+}
@@ -1,12 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.28.1
-// 	protoc        v3.19.6
-// source: tinypenguin/task.proto
-
-// Code generated by protoc-gen-go. DO NOT EDIT.
-// versions:
-// 	protoc-gen-go v1.36.10
+// 	protoc-gen-go v1.36.6
 // 	protoc        v3.19.4
 // source: tinypenguin/task.proto
 
@@ -36,6 +30,7 @@ const (
 	TaskStatus_TASK_STATUS_COMPLETED   TaskStatus = 2
 	TaskStatus_TASK_STATUS_CANCELLED   TaskStatus = 3
 	TaskStatus_TASK_STATUS_FAILED      TaskStatus = 4
+	TaskStatus_TASK_STATUS_QUEUED      TaskStatus = 5
 )
 
 // Enum value maps for TaskStatus.
@@ -46,6 +41,7 @@ var (
 		2: "TASK_STATUS_COMPLETED",
 		3: "TASK_STATUS_CANCELLED",
 		4: "TASK_STATUS_FAILED",
+		5: "TASK_STATUS_QUEUED",
 	}
 	TaskStatus_value = map[string]int32{
 		"TASK_STATUS_UNSPECIFIED": 0,
@@ -53,6 +49,7 @@ var (
 		"TASK_STATUS_COMPLETED":   2,
 		"TASK_STATUS_CANCELLED":   3,
 		"TASK_STATUS_FAILED":      4,
+		"TASK_STATUS_QUEUED":      5,
 	}
 )
 
@@ -86,6 +83,8 @@ func (TaskStatus) EnumDescriptor() ([]byte, []int) {
 type ExecuteTaskRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Model         string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`
+	Tools         *bool                  `protobuf:"varint,3,opt,name=tools,proto3,oneof" json:"tools,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -127,6 +126,20 @@ func (x *ExecuteTaskRequest) GetQuery() string {
 	return ""
 }
 
+func (x *ExecuteTaskRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *ExecuteTaskRequest) GetTools() bool {
+	if x != nil && x.Tools != nil {
+		return *x.Tools
+	}
+	return false
+}
+
 type ExecuteTaskResponse struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to Response:
@@ -681,9 +694,12 @@ var File_tinypenguin_task_proto protoreflect.FileDescriptor
 
 const file_tinypenguin_task_proto_rawDesc = "" +
 	"\n" +
-	"\x16tinypenguin/task.proto\x12\vtinypenguin\x1a\x1fgoogle/protobuf/timestamp.proto\"*\n" +
+	"\x16tinypenguin/task.proto\x12\vtinypenguin\x1a\x1fgoogle/protobuf/timestamp.proto\"e\n" +
 	"\x12ExecuteTaskRequest\x12\x14\n" +
-	"\x05query\x18\x01 \x01(\tR\x05query\"\x9a\x02\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\x12\x19\n" +
+	"\x05tools\x18\x03 \x01(\bH\x00R\x05tools\x88\x01\x01B\b\n" +
+	"\x06_tools\"\x9a\x02\n" +
 	"\x13ExecuteTaskResponse\x12=\n" +
 	"\ftask_started\x18\x01 \x01(\v2\x18.tinypenguin.TaskStartedH\x00R\vtaskStarted\x12:\n" +
 	"\vtask_output\x18\x02 \x01(\v2\x17.tinypenguin.TaskOutputH\x00R\n" +
@@ -718,14 +734,15 @@ const file_tinypenguin_task_proto_rawDesc = "" +
 	"\x05query\x18\x02 \x01(\tR\x05query\x12/\n" +
 	"\x06status\x18\x03 \x01(\x0e2\x17.tinypenguin.TaskStatusR\x06status\x129\n" +
 	"\n" +
-	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt*\x90\x01\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt*\xa8\x01\n" +
 	"\n" +
 	"TaskStatus\x12\x1b\n" +
 	"\x17TASK_STATUS_UNSPECIFIED\x10\x00\x12\x17\n" +
 	"\x13TASK_STATUS_RUNNING\x10\x01\x12\x19\n" +
 	"\x15TASK_STATUS_COMPLETED\x10\x02\x12\x19\n" +
 	"\x15TASK_STATUS_CANCELLED\x10\x03\x12\x16\n" +
-	"\x12TASK_STATUS_FAILED\x10\x042\x82\x02\n" +
+	"\x12TASK_STATUS_FAILED\x10\x04\x12\x16\n" +
+	"\x12TASK_STATUS_QUEUED\x10\x052\x82\x02\n" +
 	"\vTaskService\x12T\n" +
 	"\vExecuteTask\x12\x1f.tinypenguin.ExecuteTaskRequest\x1a .tinypenguin.ExecuteTaskResponse\"\x000\x01\x12O\n" +
 	"\n" +
@@ -787,6 +804,7 @@ func file_tinypenguin_task_proto_init() {
 	if File_tinypenguin_task_proto != nil {
 		return
 	}
+	file_tinypenguin_task_proto_msgTypes[0].OneofWrappers = []any{}
 	file_tinypenguin_task_proto_msgTypes[1].OneofWrappers = []any{
 		(*ExecuteTaskResponse_TaskStarted)(nil),
 		(*ExecuteTaskResponse_TaskOutput)(nil),
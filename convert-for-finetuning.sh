@@ -22,7 +22,7 @@ echo ""
 
 cd "$SCRIPT_DIR"
 
-go run convert_logs_for_finetuning.go "$LOG_FILE" "$OUTPUT_FILE" --min-rating "$MIN_RATING"
+go run convert_logs_for_finetuning.go "$LOG_FILE" -o "$OUTPUT_FILE" --min-rating "$MIN_RATING"
 
 if [ -f "$OUTPUT_FILE" ]; then
     LINE_COUNT=$(wc -l < "$OUTPUT_FILE")
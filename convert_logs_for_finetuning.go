@@ -2,10 +2,18 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
 // ToolCallLog represents the structure from tool_calls.log (both old and new format)
@@ -22,6 +30,9 @@ type ToolCallLog struct {
 	ErrorDetails  string `json:"error_details,omitempty"`
 	ToolsEnabled  bool   `json:"tools_enabled"`
 	Rating        int    `json:"rating,omitempty"`
+
+	Edited            bool   `json:"edited,omitempty"`             // The user corrected the arguments before the call ran
+	ProposedArguments string `json:"proposed_arguments,omitempty"` // The model's arguments when Edited
 }
 
 // ModelResponse represents the parsed model response structure
@@ -52,53 +63,710 @@ type Message struct {
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 }
 
+// ShareGPTExample is the ShareGPT conversation format with function calling
+// turns, as used by LLaMA-Factory and similar trainers
+type ShareGPTExample struct {
+	Conversations []ShareGPTTurn `json:"conversations"`
+}
+
+type ShareGPTTurn struct {
+	From  string `json:"from"` // system, human, gpt, function_call or observation
+	Value string `json:"value"`
+}
+
+// AlpacaExample is the Alpaca instruction format. Tool calls and results are
+// flattened into the output text.
+type AlpacaExample struct {
+	Instruction string `json:"instruction"`
+	Input       string `json:"input"`
+	Output      string `json:"output"`
+	System      string `json:"system,omitempty"`
+}
+
+// outputFormats lists the supported --format values
+var outputFormats = []string{"openai", "sharegpt", "alpaca"}
+
+// convertOptions holds the parsed command line options
+type convertOptions struct {
+	inputFiles []string
+	outputFile string
+	minRating  int
+	stats      bool // print dataset statistics instead of writing JSONL
+	statsJSON  bool // print statistics as JSON
+
+	systemPrompt string    // prepended as a system message when set
+	fewShot      []Message // prepended after the system message when set
+	format       string // openai, sharegpt or alpaca
+
+	tools  []string  // only include these tools when set
+	models []string  // only include these models when set
+	since  time.Time // only include entries at or after this time when set
+	until  time.Time // only include entries before this time when set
+
+	validate     bool   // check the written output after converting
+	validateFile string // check this existing file instead of converting
+
+	splitTools bool // one example per tool call instead of one per entry with all calls
+
+	includeFailures bool // keep non-success entries (denied, error, ...) as well
+	failuresOnly    bool // keep only non-success entries
+
+	queryRules []queryRule // from --query-map, tried before defaultQueryRules
+}
+
+// maxReportedProblems caps how many offending lines validation prints
+const maxReportedProblems = 10
+
+// filterNames lists the entry filters in the order they are reported
+var filterNames = []string{"rating", "tool", "model", "since", "until"}
+
+// excludedBy returns the name of the first filter that rejects the entry, or
+// an empty string when the entry passes them all
+func (opts *convertOptions) excludedBy(logEntry ToolCallLog) string {
+	succeeded := logEntry.Status == "success"
+	switch {
+	case opts.failuresOnly && succeeded,
+		!opts.failuresOnly && !opts.includeFailures && !succeeded:
+		return "status"
+	case logEntry.Rating > 0 && logEntry.Rating < opts.minRating:
+		return "rating"
+	case len(opts.tools) > 0 && !contains(opts.tools, logEntry.ToolName):
+		return "tool"
+	case len(opts.models) > 0 && !contains(opts.models, logEntry.Model):
+		return "model"
+	}
+
+	// Entries whose timestamp cannot be parsed never match a date filter
+	if opts.since.IsZero() && opts.until.IsZero() {
+		return ""
+	}
+	ts, err := time.Parse(time.RFC3339Nano, logEntry.Timestamp)
+	switch {
+	case !opts.since.IsZero() && (err != nil || ts.Before(opts.since)):
+		return "since"
+	case !opts.until.IsZero() && (err != nil || !ts.Before(opts.until)):
+		return "until"
+	}
+	return ""
+}
+
+// filterFlag returns the option name of a filter for reporting
+func filterFlag(name string) string {
+	if name == "rating" {
+		return "min-rating"
+	}
+	return name
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTime accepts RFC3339 timestamps or plain dates (midnight local time)
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, time.Local)
+}
+
+// splitList splits a comma-separated option value
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func printUsage() {
+	fmt.Println("Usage: go run convert_logs_for_finetuning.go <tool_calls.log> [more.log ...] [-o output.jsonl] [--glob PATTERN] [--min-rating N]")
+	fmt.Println("Converts tool_calls.log entries to Qwen fine-tuning format (gzipped rotated logs are read transparently)")
+	fmt.Println("Options:")
+	fmt.Println("  -o, --output FILE  Output file (default: finetuning_data.jsonl)")
+	fmt.Println("  --glob PATTERN     Add every log file matching PATTERN (e.g. 'tool_calls.log*')")
+	fmt.Println("  --min-rating N     Only include examples with rating >= N (default: 3)")
+	fmt.Println("  --stats            Print dataset statistics instead of writing the JSONL")
+	fmt.Println("  --stats-json       Like --stats, but print the statistics as JSON")
+	fmt.Println("  --system-prompt TEXT       Prepend a system message to every example")
+	fmt.Println("  --system-prompt-file FILE  Like --system-prompt, reading the text from FILE")
+	fmt.Println("  --few-shot FILE    Prepend the JSON array of messages in FILE to every example")
+	fmt.Println("  --query-map FILE   Extra command-to-query rules for old-format entries (JSON array of {\"prefix\", \"query\"})")
+	fmt.Println("  --format FORMAT    Output format: openai (default), sharegpt or alpaca")
+	fmt.Println("  --tool NAMES       Only include these tools (comma-separated)")
+	fmt.Println("  --model NAMES      Only include entries from these models (comma-separated)")
+	fmt.Println("  --since TIME       Only include entries at or after TIME (RFC3339 or YYYY-MM-DD)")
+	fmt.Println("  --until TIME       Only include entries before TIME (RFC3339 or YYYY-MM-DD)")
+	fmt.Println("  --include-failures Also include denied, failed and other non-success entries (skipped by default)")
+	fmt.Println("  --failures-only    Only include non-success entries, e.g. to teach error handling")
+	fmt.Println("  --validate         Check the written output before reporting success")
+	fmt.Println("  --validate-file FILE  Only check an existing FILE in the --format layout")
+	fmt.Println("  --split-tools      One example per tool call, preceded by the earlier calls of the same reply")
+}
+
+// parseArgs parses the converter arguments. For backward compatibility a
+// second positional argument ending in .jsonl is treated as the output file
+// when -o is not given.
+func parseArgs(args []string) (*convertOptions, error) {
+	opts := &convertOptions{minRating: 3, format: "openai"}
+	var positional []string
+	var globs []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		next := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch arg {
+		case "-o", "--output":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			opts.outputFile = v
+		case "--glob":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			globs = append(globs, v)
+		case "--stats":
+			opts.stats = true
+		case "--stats-json":
+			opts.stats = true
+			opts.statsJSON = true
+		case "--system-prompt":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			opts.systemPrompt = v
+		case "--system-prompt-file":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			data, err := os.ReadFile(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read system prompt file: %v", err)
+			}
+			opts.systemPrompt = strings.TrimSpace(string(data))
+		case "--few-shot":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if opts.fewShot, err = loadFewShot(v); err != nil {
+				return nil, err
+			}
+		case "--query-map":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			rules, err := loadQueryRules(v)
+			if err != nil {
+				return nil, err
+			}
+			opts.queryRules = append(opts.queryRules, rules...)
+		case "--format":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			valid := false
+			for _, f := range outputFormats {
+				valid = valid || v == f
+			}
+			if !valid {
+				return nil, fmt.Errorf("invalid --format %q (expected %s)", v, strings.Join(outputFormats, ", "))
+			}
+			opts.format = v
+		case "--tool", "--model":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if arg == "--tool" {
+				opts.tools = append(opts.tools, splitList(v)...)
+			} else {
+				opts.models = append(opts.models, splitList(v)...)
+			}
+		case "--since", "--until":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			t, err := parseTime(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value %q: use RFC3339 or YYYY-MM-DD", arg, v)
+			}
+			if arg == "--since" {
+				opts.since = t
+			} else {
+				opts.until = t
+			}
+		case "--validate":
+			opts.validate = true
+		case "--split-tools":
+			opts.splitTools = true
+		case "--include-failures":
+			opts.includeFailures = true
+		case "--failures-only":
+			opts.failuresOnly = true
+		case "--validate-file":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			opts.validateFile = v
+		case "--min-rating":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := fmt.Sscanf(v, "%d", &opts.minRating); err != nil {
+				return nil, fmt.Errorf("invalid --min-rating value %q", v)
+			}
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return nil, fmt.Errorf("unknown option: %s", arg)
+			}
+			positional = append(positional, arg)
+		}
+	}
+
+	if opts.includeFailures && opts.failuresOnly {
+		return nil, fmt.Errorf("--include-failures and --failures-only cannot be combined")
+	}
+
+	if opts.outputFile == "" && len(positional) == 2 && strings.HasSuffix(positional[1], ".jsonl") {
+		opts.outputFile = positional[1]
+		positional = positional[:1]
+	}
+	if opts.outputFile == "" {
+		opts.outputFile = "finetuning_data.jsonl"
+	}
+
+	opts.inputFiles = positional
+	for _, pattern := range globs {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			fmt.Fprintf(os.Stderr, "Warning: glob %q matched no files\n", pattern)
+		}
+		opts.inputFiles = append(opts.inputFiles, matches...)
+	}
+
+	// Drop repeated paths so a file named explicitly and by glob is read once
+	seen := make(map[string]bool)
+	var unique []string
+	for _, f := range opts.inputFiles {
+		if abs, err := filepath.Abs(f); err == nil && !seen[abs] {
+			seen[abs] = true
+			unique = append(unique, f)
+		}
+	}
+	opts.inputFiles = unique
+
+	if len(opts.inputFiles) == 0 && opts.validateFile == "" {
+		return nil, fmt.Errorf("no input files given")
+	}
+	return opts, nil
+}
+
+// loadFewShot reads a --few-shot file: a JSON array of messages in the
+// openai layout with at least one user and one assistant message. System
+// messages are rejected, since --system-prompt places those.
+func loadFewShot(path string) ([]Message, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read few-shot file: %v", err)
+	}
+	var messages []Message
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&messages); err != nil {
+		return nil, fmt.Errorf("few-shot file %s is not a JSON array of messages: %v", path, err)
+	}
+	for i, msg := range messages {
+		if msg.Role == "system" {
+			return nil, fmt.Errorf("few-shot file %s: message %d is a system message; use --system-prompt instead", path, i)
+		}
+	}
+	line, _ := json.Marshal(FineTuningExample{Messages: messages})
+	if err := validateLine(line, "openai"); err != nil {
+		return nil, fmt.Errorf("few-shot file %s: %v", path, err)
+	}
+	return messages, nil
+}
+
+// conversionStats tracks counts across all input files
+type conversionStats struct {
+	converted  int
+	skipped    int
+	duplicates int
+	oldFormat  int
+	perFile    map[string]int
+	filtered   map[string]int // entries excluded, by filter name
+	byStatus   map[string]int // examples written, by entry status
+	statusOut  map[string]int // entries excluded by the status filter, by status
+	dataset    *datasetStats
+}
+
+// datasetStats describes the shape of the entries that pass the filters
+type datasetStats struct {
+	Total             int            `json:"total"`
+	ByTool            map[string]int `json:"by_tool"`
+	ByStatus          map[string]int `json:"by_status"`
+	ByRating          map[string]int `json:"by_rating"`
+	ByModel           map[string]int `json:"by_model"`
+	AvgOutputLength   float64        `json:"avg_output_length"`
+	OldFormat         int            `json:"old_format_reconstructed"`
+	totalOutputLength int
+}
+
+func newDatasetStats() *datasetStats {
+	return &datasetStats{
+		ByTool:   make(map[string]int),
+		ByStatus: make(map[string]int),
+		ByRating: make(map[string]int),
+		ByModel:  make(map[string]int),
+	}
+}
+
+// add records a single log entry
+func (d *datasetStats) add(logEntry ToolCallLog) {
+	d.Total++
+	d.ByTool[valueOrUnknown(logEntry.ToolName)]++
+	d.ByStatus[valueOrUnknown(logEntry.Status)]++
+	d.ByModel[valueOrUnknown(logEntry.Model)]++
+	if logEntry.Rating > 0 {
+		d.ByRating[fmt.Sprintf("%d", logEntry.Rating)]++
+	} else {
+		d.ByRating["unrated"]++
+	}
+	if logEntry.UserQuery == "" || logEntry.ModelResponse == "" {
+		d.OldFormat++
+	}
+	d.totalOutputLength += len(logEntry.Output)
+	d.AvgOutputLength = float64(d.totalOutputLength) / float64(d.Total)
+}
+
+// print writes the statistics as a human readable table
+func (d *datasetStats) print() {
+	fmt.Printf("\n📊 Dataset statistics\n")
+	fmt.Printf("  Total entries: %d\n", d.Total)
+	fmt.Printf("  Old format (reconstructed): %d\n", d.OldFormat)
+	fmt.Printf("  Average output length: %.1f bytes\n", d.AvgOutputLength)
+	printCounts("By tool", d.ByTool, d.Total)
+	printCounts("By status", d.ByStatus, d.Total)
+	printCounts("By rating", d.ByRating, d.Total)
+	printCounts("By model", d.ByModel, d.Total)
+}
+
+// printCounts prints a count table sorted by descending count
+func printCounts(title string, counts map[string]int, total int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	fmt.Printf("\n  %s:\n", title)
+	for _, k := range keys {
+		pct := 0.0
+		if total > 0 {
+			pct = float64(counts[k]) * 100 / float64(total)
+		}
+		fmt.Printf("    %-30s %6d  %5.1f%%\n", k, counts[k], pct)
+	}
+}
+
+// formatCounts renders counts as "a 3, b 1", sorted by descending count
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s %d", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func valueOrUnknown(s string) string {
+	if s == "" {
+		return "(unknown)"
+	}
+	return s
+}
+
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run convert_logs_for_finetuning.go <tool_calls.log> [output.jsonl] [--min-rating N]")
-		fmt.Println("Converts tool_calls.log entries to Qwen fine-tuning format")
-		fmt.Println("Options:")
-		fmt.Println("  --min-rating N    Only include examples with rating >= N (default: 3)")
+		printUsage()
+		os.Exit(1)
+	}
+
+	opts, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		printUsage()
 		os.Exit(1)
 	}
 
-	inputFile := os.Args[1]
-	outputFile := "finetuning_data.jsonl"
-	minRating := 3
+	if opts.validateFile != "" {
+		if !validateOutput(opts.validateFile, opts.format) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	stats := &conversionStats{
+		perFile:   make(map[string]int),
+		filtered:  make(map[string]int),
+		byStatus:  make(map[string]int),
+		statusOut: make(map[string]int),
+	}
 
-	// Parse arguments
-	for i, arg := range os.Args {
-		if arg == "--min-rating" && i+1 < len(os.Args) {
-			fmt.Sscanf(os.Args[i+1], "%d", &minRating)
+	var writer *bufio.Writer
+	var outFile *os.File
+	if opts.stats {
+		stats.dataset = newDatasetStats()
+	} else {
+		// Open output file
+		outFile, err = os.Create(opts.outputFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			os.Exit(1)
 		}
-		if i == 2 && !strings.HasPrefix(arg, "--") {
-			outputFile = arg
+		defer outFile.Close()
+
+		writer = bufio.NewWriter(outFile)
+		defer writer.Flush()
+	}
+	// Entries already seen across all inputs, so logs copied between
+	// machines are not counted twice
+	seen := make(map[string]bool)
+
+	for _, inputFile := range opts.inputFiles {
+		if err := convertFile(inputFile, opts, writer, seen, stats); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", inputFile, err)
+			os.Exit(1)
 		}
 	}
 
-	// Open input file
-	file, err := os.Open(inputFile)
+	if opts.stats {
+		if opts.statsJSON {
+			data, _ := json.MarshalIndent(stats.dataset, "", "  ")
+			fmt.Println(string(data))
+		} else {
+			stats.dataset.print()
+		}
+		return
+	}
+
+	fmt.Printf("\n✅ Conversion complete!\n")
+	fmt.Printf("  ✅ Converted: %d examples\n", stats.converted)
+	fmt.Printf("  ⚠️  Skipped: %d entries\n", stats.skipped)
+	fmt.Printf("  🔁 Duplicates removed: %d entries\n", stats.duplicates)
+	fmt.Printf("  📝 Old format (reconstructed): %d entries\n", stats.oldFormat)
+	for _, name := range filterNames {
+		if n := stats.filtered[name]; n > 0 {
+			fmt.Printf("  🔍 Excluded by --%s filter: %d entries\n", filterFlag(name), n)
+		}
+	}
+	if len(stats.statusOut) > 0 {
+		hint := "--include-failures keeps them"
+		if opts.failuresOnly {
+			hint = "--failures-only"
+		}
+		fmt.Printf("  🚫 Excluded by status: %s (%s)\n", formatCounts(stats.statusOut), hint)
+	}
+	if len(stats.byStatus) > 0 {
+		fmt.Printf("  📊 Converted by status: %s\n", formatCounts(stats.byStatus))
+	}
+	if len(opts.inputFiles) > 1 {
+		fmt.Printf("  📂 Per-file contribution:\n")
+		for _, f := range opts.inputFiles {
+			fmt.Printf("     %s: %d examples\n", f, stats.perFile[f])
+		}
+	}
+	fmt.Printf("  📄 Output file: %s (%s format)\n", opts.outputFile, opts.format)
+	fmt.Printf("  ⭐ Minimum rating filter: %d+\n", opts.minRating)
+
+	if opts.validate {
+		if err := writer.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output file: %v\n", err)
+			os.Exit(1)
+		}
+		outFile.Close()
+		if !validateOutput(opts.outputFile, opts.format) {
+			os.Exit(1)
+		}
+	}
+}
+
+// validateOutput re-reads a converted JSONL file and checks every line
+// against the layout of format. It prints the first offending lines and
+// reports whether the file is valid.
+func validateOutput(path, format string) bool {
+	file, err := os.Open(path)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening input file: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", path, err)
+		return false
 	}
 	defer file.Close()
 
-	// Open output file
-	outFile, err := os.Create(outputFile)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
-		os.Exit(1)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNum, checked, invalid := 0, 0, 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		checked++
+		if err := validateLine([]byte(line), format); err != nil {
+			invalid++
+			if invalid <= maxReportedProblems {
+				fmt.Fprintf(os.Stderr, "  ❌ %s line %d: %v\n", path, lineNum, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+		return false
+	}
+
+	if invalid > maxReportedProblems {
+		fmt.Fprintf(os.Stderr, "  ... and %d more invalid lines\n", invalid-maxReportedProblems)
 	}
-	defer outFile.Close()
+	if invalid > 0 {
+		fmt.Printf("\n❌ Validation failed: %d of %d lines invalid in %s (%s format)\n", invalid, checked, path, format)
+		return false
+	}
+	fmt.Printf("\n✅ Validation passed: %d lines checked in %s (%s format)\n", checked, path, format)
+	return true
+}
 
-	writer := bufio.NewWriter(outFile)
-	defer writer.Flush()
+// validateLine checks that a single JSONL line is a well-formed example
+func validateLine(line []byte, format string) error {
+	switch format {
+	case "sharegpt":
+		var example ShareGPTExample
+		if err := json.Unmarshal(line, &example); err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+		var human, gpt bool
+		for i, turn := range example.Conversations {
+			switch turn.From {
+			case "human":
+				human = true
+			case "gpt":
+				gpt = true
+			case "function_call":
+				gpt = true
+				var call struct {
+					Name string `json:"name"`
+				}
+				if err := json.Unmarshal([]byte(turn.Value), &call); err != nil || call.Name == "" {
+					return fmt.Errorf("turn %d: function_call is not a JSON object with a name", i)
+				}
+			case "system", "observation":
+			default:
+				return fmt.Errorf("turn %d: unknown sender %q", i, turn.From)
+			}
+		}
+		if !human || !gpt {
+			return fmt.Errorf("needs at least one human and one gpt or function_call turn")
+		}
+	case "alpaca":
+		var example AlpacaExample
+		if err := json.Unmarshal(line, &example); err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+		if strings.TrimSpace(example.Instruction) == "" {
+			return fmt.Errorf("empty instruction")
+		}
+		if strings.TrimSpace(example.Output) == "" {
+			return fmt.Errorf("empty output")
+		}
+	default:
+		var example FineTuningExample
+		if err := json.Unmarshal(line, &example); err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+		var user, assistant bool
+		for i, msg := range example.Messages {
+			switch msg.Role {
+			case "user":
+				user = true
+			case "assistant":
+				assistant = true
+			case "system", "tool":
+			default:
+				return fmt.Errorf("message %d: unknown role %q", i, msg.Role)
+			}
+			for _, tc := range msg.ToolCalls {
+				if tc.Function.Name == "" {
+					return fmt.Errorf("message %d: tool call without a function name", i)
+				}
+				if !json.Valid([]byte(tc.Function.Arguments)) {
+					return fmt.Errorf("message %d: arguments of %s are not valid JSON", i, tc.Function.Name)
+				}
+			}
+		}
+		if !user || !assistant {
+			return fmt.Errorf("needs at least one user and one assistant message")
+		}
+	}
+	return nil
+}
+
+// convertFile converts every entry of a single log file and appends the
+// resulting examples to writer
+func convertFile(inputFile string, opts *convertOptions, writer *bufio.Writer, seen map[string]bool, stats *conversionStats) error {
+	file, err := openLog(inputFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
 	lineNum := 0
-	converted := 0
-	skipped := 0
-	oldFormat := 0
+	// Entries logged so far for the reply the current entry belongs to,
+	// the preceding context of a --split-tools example
+	var episode []ToolCallLog
 
 	for scanner.Scan() {
 		lineNum++
@@ -109,84 +777,334 @@ func main() {
 
 		var logEntry ToolCallLog
 		if err := json.Unmarshal([]byte(line), &logEntry); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to parse line %d: %v\n", lineNum, err)
-			skipped++
+			fmt.Fprintf(os.Stderr, "Warning: Failed to parse %s line %d: %v\n", inputFile, lineNum, err)
+			stats.skipped++
+			continue
+		}
+
+		// Skip entries already converted from another file
+		key := entryKey(logEntry)
+		if seen[key] {
+			stats.duplicates++
+			continue
+		}
+		seen[key] = true
+
+		var context []ToolCallLog
+		if opts.splitTools {
+			if sameReply(episode, logEntry) {
+				context = episode
+			}
+			episode = append(context, logEntry)
+		}
+
+		// Skip low-rated and filtered-out entries
+		if filter := opts.excludedBy(logEntry); filter == "status" {
+			stats.statusOut[valueOrUnknown(logEntry.Status)]++
+			continue
+		} else if filter != "" {
+			stats.filtered[filter]++
 			continue
 		}
 
-		// Skip low-rated entries
-		if logEntry.Rating > 0 && logEntry.Rating < minRating {
-			skipped++
+		if stats.dataset != nil {
+			stats.dataset.add(logEntry)
 			continue
 		}
 
 		// Create fine-tuning example
-		example, err := createFineTuningExample(logEntry)
+		var example *FineTuningExample
+		if opts.splitTools {
+			example, err = createStepExample(logEntry, context)
+		} else {
+			example, err = createFineTuningExample(logEntry)
+		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to create example from line %d: %v\n", lineNum, err)
-			skipped++
+			fmt.Fprintf(os.Stderr, "Warning: Failed to create example from %s line %d: %v\n", inputFile, lineNum, err)
+			stats.skipped++
 			continue
 		}
 
 		if example == nil {
 			// Old format without user_query - skip or reconstruct
-			oldFormat++
+			stats.oldFormat++
 			if logEntry.UserQuery == "" {
 				// Try to reconstruct from tool call
-				example = reconstructExample(logEntry)
+				example = reconstructExample(logEntry, opts.queryRules)
 				if example == nil {
-					skipped++
+					stats.skipped++
 					continue
 				}
 			} else {
-				skipped++
+				stats.skipped++
 				continue
 			}
 		}
 
+		if len(opts.fewShot) > 0 {
+			example.Messages = append(append([]Message{}, opts.fewShot...), example.Messages...)
+		}
+		if opts.systemPrompt != "" {
+			example.Messages = append([]Message{{Role: "system", Content: opts.systemPrompt}}, example.Messages...)
+		}
+
 		// Write as JSONL
-		jsonData, err := json.Marshal(example)
+		jsonData, err := json.Marshal(formatExample(example, opts.format))
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to marshal example on line %d: %v\n", lineNum, err)
-			skipped++
+			fmt.Fprintf(os.Stderr, "Warning: Failed to marshal example from %s line %d: %v\n", inputFile, lineNum, err)
+			stats.skipped++
 			continue
 		}
 
 		writer.WriteString(string(jsonData) + "\n")
-		converted++
+		stats.converted++
+		stats.byStatus[valueOrUnknown(logEntry.Status)]++
+		stats.perFile[inputFile]++
 	}
 
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
-		os.Exit(1)
+	return scanner.Err()
+}
+
+// gzipReadCloser closes both the gzip stream and the underlying file
+type gzipReadCloser struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipReadCloser) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// openLog opens a log file for reading, transparently decompressing rotated
+// logs that are gzipped. Compression is detected from the magic bytes, so
+// the .gz extension is not required.
+func openLog(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("\n✅ Conversion complete!\n")
-	fmt.Printf("  ✅ Converted: %d examples\n", converted)
-	fmt.Printf("  ⚠️  Skipped: %d entries\n", skipped)
-	fmt.Printf("  📝 Old format (reconstructed): %d entries\n", oldFormat)
-	fmt.Printf("  📄 Output file: %s\n", outputFile)
-	fmt.Printf("  ⭐ Minimum rating filter: %d+\n", minRating)
+	magic := make([]byte, 2)
+	n, _ := io.ReadFull(file, magic)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if n < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+		return file, nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("invalid gzip file: %v", err)
+	}
+	return &gzipReadCloser{Reader: gz, file: file}, nil
 }
 
-func createFineTuningExample(logEntry ToolCallLog) (*FineTuningExample, error) {
-	// Check if we have the new format with user_query and model_response
-	if logEntry.UserQuery == "" || logEntry.ModelResponse == "" {
-		return nil, fmt.Errorf("missing user_query or model_response")
+// formatExample converts an example from the OpenAI messages layout to the
+// requested output format
+func formatExample(example *FineTuningExample, format string) interface{} {
+	switch format {
+	case "sharegpt":
+		return toShareGPT(example)
+	case "alpaca":
+		return toAlpaca(example)
+	default:
+		return example
 	}
+}
 
-	// Parse the model response
+// toShareGPT maps each message to a ShareGPT turn, splitting assistant tool
+// calls into function_call turns and tool results into observation turns
+func toShareGPT(example *FineTuningExample) *ShareGPTExample {
+	out := &ShareGPTExample{}
+	for _, msg := range example.Messages {
+		switch msg.Role {
+		case "system":
+			out.Conversations = append(out.Conversations, ShareGPTTurn{From: "system", Value: msg.Content})
+		case "user":
+			out.Conversations = append(out.Conversations, ShareGPTTurn{From: "human", Value: msg.Content})
+		case "assistant":
+			if msg.Content != "" {
+				out.Conversations = append(out.Conversations, ShareGPTTurn{From: "gpt", Value: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				out.Conversations = append(out.Conversations, ShareGPTTurn{From: "function_call", Value: toolCallText(tc)})
+			}
+		case "tool":
+			out.Conversations = append(out.Conversations, ShareGPTTurn{From: "observation", Value: msg.Content})
+		}
+	}
+	return out
+}
+
+// toAlpaca maps the user query to the instruction and flattens the assistant
+// reply, its tool calls and the tool result into the output text
+func toAlpaca(example *FineTuningExample) *AlpacaExample {
+	out := &AlpacaExample{}
+	var output []string
+	for _, msg := range example.Messages {
+		switch msg.Role {
+		case "system":
+			out.System = msg.Content
+		case "user":
+			out.Instruction = msg.Content
+		case "assistant":
+			if msg.Content != "" {
+				output = append(output, msg.Content)
+			}
+			for _, tc := range msg.ToolCalls {
+				output = append(output, "<tool_call>\n"+toolCallText(tc)+"\n</tool_call>")
+			}
+		case "tool":
+			output = append(output, msg.Content)
+		}
+	}
+	out.Output = strings.Join(output, "\n\n")
+	return out
+}
+
+// toolCallText renders a tool call as {"name": ..., "arguments": ...}
+func toolCallText(tc ToolCall) string {
+	var args interface{} = tc.Function.Arguments
+	var parsed interface{}
+	if json.Unmarshal([]byte(tc.Function.Arguments), &parsed) == nil {
+		args = parsed
+	}
+	data, _ := json.Marshal(map[string]interface{}{"name": tc.Function.Name, "arguments": args})
+	return string(data)
+}
+
+// entryKey returns a content hash identifying a log entry
+func entryKey(logEntry ToolCallLog) string {
+	data, _ := json.Marshal(logEntry)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// parseModelResponse parses the assistant message logged with an entry
+func parseModelResponse(logEntry ToolCallLog) (ModelResponse, error) {
 	var modelResp ModelResponse
 	if err := json.Unmarshal([]byte(logEntry.ModelResponse), &modelResp); err != nil {
 		// Try to parse as a Message directly
 		var msg Message
 		if err2 := json.Unmarshal([]byte(logEntry.ModelResponse), &msg); err2 != nil {
-			return nil, fmt.Errorf("failed to parse model_response: %v", err)
+			return modelResp, fmt.Errorf("failed to parse model_response: %v", err)
 		}
 		modelResp.Role = msg.Role
 		modelResp.Content = msg.Content
 		modelResp.ToolCalls = msg.ToolCalls
 	}
+	return modelResp, nil
+}
+
+// sameReply reports whether logEntry is a further tool call of the reply
+// whose calls are logged in episode: same query and model response, and the
+// response has calls left. A repeated run of the same query starts afresh
+// once the response's calls are used up.
+func sameReply(episode []ToolCallLog, logEntry ToolCallLog) bool {
+	if len(episode) == 0 || logEntry.UserQuery == "" || logEntry.ModelResponse == "" ||
+		episode[0].UserQuery != logEntry.UserQuery || episode[0].ModelResponse != logEntry.ModelResponse {
+		return false
+	}
+	modelResp, err := parseModelResponse(logEntry)
+	return err == nil && len(episode) < len(modelResp.ToolCalls)
+}
+
+// createStepExample builds a --split-tools example: the user query, the
+// calls that came before this one in the same reply with their results, then
+// this entry's call alone and its result. The reply's text goes with the
+// first call.
+func createStepExample(logEntry ToolCallLog, context []ToolCallLog) (*FineTuningExample, error) {
+	if logEntry.UserQuery == "" || logEntry.ModelResponse == "" {
+		return nil, nil
+	}
+	modelResp, err := parseModelResponse(logEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []Message{{Role: "user", Content: logEntry.UserQuery}}
+	for i, step := range append(context, logEntry) {
+		assistantMsg := Message{Role: "assistant", ToolCalls: []ToolCall{loggedToolCall(step, modelResp, i)}}
+		if i == 0 {
+			assistantMsg.Content = modelResp.Content
+		}
+		messages = append(messages, assistantMsg)
+
+		// Earlier calls always get a result, so the assistant turns do not
+		// follow each other; the final call keeps the usual success-only rule
+		switch {
+		case i < len(context):
+			output := step.Output
+			if step.Status != "success" {
+				output = step.Message
+			}
+			messages = append(messages, Message{
+				Role:    "tool",
+				Content: fmt.Sprintf("Tool execution result:\nStatus: %s\nOutput: %s", step.Status, output),
+			})
+		case step.Status == "success" && step.Output != "":
+			messages = append(messages, Message{
+				Role:    "tool",
+				Content: fmt.Sprintf("Tool execution result:\nStatus: %s\nOutput: %s", step.Status, step.Output),
+			})
+		}
+	}
+	return &FineTuningExample{Messages: messages}, nil
+}
+
+// loggedToolCall returns the call of modelResp that an entry logged, matched
+// by name and arguments so its ID is kept, or rebuilds it from the entry
+func loggedToolCall(logEntry ToolCallLog, modelResp ModelResponse, index int) ToolCall {
+	for _, tc := range modelResp.ToolCalls {
+		if isLoggedCall(tc, logEntry) {
+			tc.Function.Arguments = logEntry.Arguments
+			return tc
+		}
+	}
+	toolCall := ToolCall{
+		ID:   fmt.Sprintf("call_%d", index+1),
+		Type: "function",
+	}
+	toolCall.Function.Name = logEntry.ToolName
+	toolCall.Function.Arguments = logEntry.Arguments
+	return toolCall
+}
+
+// isLoggedCall reports whether tc is the call an entry logged. For a call the
+// user edited the model proposed the original arguments.
+func isLoggedCall(tc ToolCall, logEntry ToolCallLog) bool {
+	arguments := logEntry.Arguments
+	if logEntry.Edited {
+		arguments = logEntry.ProposedArguments
+	}
+	return tc.Function.Name == logEntry.ToolName && compactJSON(tc.Function.Arguments) == compactJSON(arguments)
+}
+
+// compactJSON removes insignificant whitespace so equal arguments compare
+// equal however they were formatted
+func compactJSON(s string) string {
+	var buf bytes.Buffer
+	if json.Compact(&buf, []byte(s)) != nil {
+		return s
+	}
+	return buf.String()
+}
+
+func createFineTuningExample(logEntry ToolCallLog) (*FineTuningExample, error) {
+	// Old format entries without user_query or model_response are left to
+	// the caller to reconstruct
+	if logEntry.UserQuery == "" || logEntry.ModelResponse == "" {
+		return nil, nil
+	}
+
+	modelResp, err := parseModelResponse(logEntry)
+	if err != nil {
+		return nil, err
+	}
 
 	// Build the messages array
 	messages := []Message{
@@ -205,6 +1123,15 @@ func createFineTuningExample(logEntry ToolCallLog) (*FineTuningExample, error) {
 	// Convert tool calls to the format expected by Qwen
 	if len(modelResp.ToolCalls) > 0 {
 		assistantMsg.ToolCalls = modelResp.ToolCalls
+		// Train on the command the user corrected it to, not the proposal
+		if logEntry.Edited {
+			assistantMsg.ToolCalls = append([]ToolCall{}, modelResp.ToolCalls...)
+			for i, tc := range assistantMsg.ToolCalls {
+				if isLoggedCall(tc, logEntry) {
+					assistantMsg.ToolCalls[i].Function.Arguments = logEntry.Arguments
+				}
+			}
+		}
 	} else {
 		// Reconstruct tool call from log entry
 		toolCall := ToolCall{
@@ -232,9 +1159,9 @@ func createFineTuningExample(logEntry ToolCallLog) (*FineTuningExample, error) {
 	}, nil
 }
 
-func reconstructExample(logEntry ToolCallLog) *FineTuningExample {
+func reconstructExample(logEntry ToolCallLog, rules []queryRule) *FineTuningExample {
 	// Reconstruct user query from tool call (best effort)
-	userQuery := reconstructUserQuery(logEntry)
+	userQuery := reconstructUserQuery(logEntry, rules)
 
 	// Create assistant response with tool call
 	assistantResponse := createAssistantResponse(logEntry)
@@ -275,7 +1202,103 @@ func reconstructExample(logEntry ToolCallLog) *FineTuningExample {
 	}
 }
 
-func reconstructUserQuery(logEntry ToolCallLog) string {
+// queryRule maps a command back to the request that likely produced it, for
+// old-format entries logged without the user's query
+type queryRule struct {
+	Prefix string `json:"prefix"` // Leading words of the command, e.g. "systemctl status"
+	Query  string `json:"query"`  // The request; {args} stands for the first operands after the prefix and requires some
+}
+
+// defaultQueryRules cover common admin commands; --query-map rules are tried
+// first. Among the rules that match, the longest prefix wins.
+var defaultQueryRules = []queryRule{
+	{"who", "Check current users"},
+	{"w", "Check current users"},
+	{"whoami", "Which user am I?"},
+	{"pwd", "What's the current directory?"},
+	{"ls", "List files in {args}"},
+	{"ls", "List files in current directory"},
+	{"ps", "Show running processes"},
+	{"uptime", "How long has the system been up?"},
+	{"uname", "Show the kernel version"},
+	{"df", "Show disk usage"},
+	{"du", "Show how much space {args} uses"},
+	{"free", "Show memory usage"},
+	{"systemctl status", "Show the status of the {args} service"},
+	{"systemctl start", "Start the {args} service"},
+	{"systemctl stop", "Stop the {args} service"},
+	{"systemctl restart", "Restart the {args} service"},
+	{"systemctl reload", "Reload the {args} service"},
+	{"systemctl enable", "Enable the {args} service at boot"},
+	{"systemctl disable", "Disable the {args} service at boot"},
+	{"systemctl is-active", "Is the {args} service running?"},
+	{"systemctl --failed", "Show failed services"},
+	{"systemctl list-units", "List the systemd units"},
+	{"journalctl -u", "Show the logs of the {args} service"},
+	{"ip a", "Show the network interfaces and their addresses"},
+	{"ip addr", "Show the network interfaces and their addresses"},
+	{"ip r", "Show the routing table"},
+	{"ip route", "Show the routing table"},
+	{"ip link", "Show the network links"},
+	{"firewall-cmd --state", "Is the firewall running?"},
+	{"firewall-cmd --list-all", "Show the firewall configuration"},
+	{"firewall-cmd --reload", "Reload the firewall rules"},
+	{"firewall-cmd --get-active-zones", "Show the active firewall zones"},
+}
+
+// loadQueryRules reads --query-map: a JSON array of queryRule objects
+func loadQueryRules(path string) ([]queryRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read query map: %v", err)
+	}
+	var rules []queryRule
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("query map %s is not a JSON array of {\"prefix\", \"query\"} objects: %v", path, err)
+	}
+	for i, rule := range rules {
+		if strings.TrimSpace(rule.Prefix) == "" || strings.TrimSpace(rule.Query) == "" {
+			return nil, fmt.Errorf("query map %s: rule %d needs both a prefix and a query", path, i)
+		}
+	}
+	return rules, nil
+}
+
+// matchQueryRule returns the query of the best rule for a command, or an
+// empty string when none matches. Prefixes match whole words, so "ls" does
+// not match "lsblk", and a leading sudo is ignored.
+func matchQueryRule(command string, rules []queryRule) string {
+	words := strings.Fields(command)
+	if len(words) > 0 && words[0] == "sudo" {
+		words = words[1:]
+	}
+	best, bestLen := "", 0
+	for _, rule := range rules {
+		prefix := strings.Fields(rule.Prefix)
+		if len(prefix) <= bestLen || len(prefix) > len(words) || strings.Join(words[:len(prefix)], " ") != strings.Join(prefix, " ") {
+			continue
+		}
+		// Operands up to the next option, so option values are left out
+		var operands []string
+		for _, word := range words[len(prefix):] {
+			if !strings.HasPrefix(word, "-") {
+				operands = append(operands, word)
+			} else if len(operands) > 0 {
+				break
+			}
+		}
+		rest := strings.Join(operands, " ")
+		if strings.Contains(rule.Query, "{args}") && rest == "" {
+			continue
+		}
+		best, bestLen = strings.ReplaceAll(rule.Query, "{args}", rest), len(prefix)
+	}
+	return best
+}
+
+func reconstructUserQuery(logEntry ToolCallLog, rules []queryRule) string {
 	// Try to infer the user query from the tool call
 	var args map[string]interface{}
 	json.Unmarshal([]byte(logEntry.Arguments), &args)
@@ -284,17 +1307,8 @@ func reconstructUserQuery(logEntry ToolCallLog) string {
 	case "run_commands":
 		if cmd, ok := args["command"].(string); ok {
 			// Try to make it more natural
-			if strings.HasPrefix(cmd, "who") || strings.HasPrefix(cmd, "w ") {
-				return "Check current users"
-			}
-			if strings.HasPrefix(cmd, "pwd") {
-				return "What's the current directory?"
-			}
-			if strings.HasPrefix(cmd, "ls") {
-				return "List files in current directory"
-			}
-			if strings.HasPrefix(cmd, "ps") {
-				return "Show running processes"
+			if query := matchQueryRule(cmd, append(append([]queryRule{}, rules...), defaultQueryRules...)); query != "" {
+				return query
 			}
 			return fmt.Sprintf("Execute: %s", cmd)
 		}
@@ -317,13 +1331,13 @@ func createAssistantResponse(logEntry ToolCallLog) string {
 	toolCallJSON := fmt.Sprintf(`{"id": "call_1", "type": "function", "function": {"name": "%s", "arguments": %s}}`, 
 		logEntry.ToolName, logEntry.Arguments)
 	
-	response += fmt.Sprintf(`\n\n<tool_call>\n%s\n</tool_call>`, toolCallJSON)
+	response += fmt.Sprintf("\n\n<tool_call>\n%s\n</tool_call>", toolCallJSON)
 	
 	// Add result if available
 	if logEntry.Status == "success" && logEntry.Output != "" {
-		response += fmt.Sprintf(`\n\nTool execution completed successfully:\n%s`, logEntry.Output)
+		response += fmt.Sprintf("\n\nTool execution completed successfully:\n%s", logEntry.Output)
 	} else if logEntry.Status == "error" {
-		response += fmt.Sprintf(`\n\nTool execution failed: %s`, logEntry.Message)
+		response += fmt.Sprintf("\n\nTool execution failed: %s", logEntry.Message)
 	}
 	
 	return response
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreateAssistantResponseUsesRealNewlines(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry ToolCallLog
+		want  string
+	}{
+		{
+			name: "success",
+			entry: ToolCallLog{
+				ToolName:  "run_commands",
+				Arguments: `{"command": "uname -r"}`,
+				Status:    "success",
+				Output:    "6.8.0\n",
+			},
+			want: "I'll help you with that. Let me use the run_commands tool.\n\n<tool_call>\n" +
+				`{"id": "call_1", "type": "function", "function": {"name": "run_commands", "arguments": {"command": "uname -r"}}}` +
+				"\n</tool_call>\n\nTool execution completed successfully:\n6.8.0\n",
+		},
+		{
+			name: "error",
+			entry: ToolCallLog{
+				ToolName:  "edit_files",
+				Arguments: `{"path": "/etc/hosts"}`,
+				Status:    "error",
+				Message:   "permission denied",
+			},
+			want: "I'll help you with that. Let me use the edit_files tool.\n\n<tool_call>\n" +
+				`{"id": "call_1", "type": "function", "function": {"name": "edit_files", "arguments": {"path": "/etc/hosts"}}}` +
+				"\n</tool_call>\n\nTool execution failed: permission denied",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := createAssistantResponse(tt.entry)
+			if strings.Contains(got, `\n`) {
+				t.Errorf("response contains a literal \\n: %q", got)
+			}
+			if got != tt.want {
+				t.Errorf("response = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}